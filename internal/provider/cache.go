@@ -0,0 +1,312 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	cacheLinkModeCopy     = "copy"
+	cacheLinkModeHardlink = "hardlink"
+	cacheLinkModeSymlink  = "symlink"
+)
+
+var errOffline = errors.New("network access is disabled in offline mode")
+
+// artifactCache is a content addressed store of downloaded files. Bodies are
+// kept under blobs/sha256/<digest> and index/<sha256 of url>.json maps a URL
+// to the digest of the content last downloaded from it.
+type artifactCache struct {
+	dir      string
+	linkMode string
+}
+
+type cacheEntry struct {
+	URL          string `json:"url"`
+	SHA256       string `json:"sha256"`
+	Size         int64  `json:"size"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// current reports whether remote still describes the cached content. The
+// size alone is not trusted, since a file can change without changing size,
+// so an entry without an ETag or Last-Modified validator is never current.
+func (e *cacheEntry) current(remote *remoteMetadata) bool {
+	if remote == nil || e.Size != remote.ContentLength {
+		return false
+	}
+
+	switch {
+	case e.ETag != "":
+		return e.ETag == remote.ETag
+	case e.LastModified != "":
+		return e.LastModified == remote.LastModified
+	}
+
+	return false
+}
+
+func newArtifactCache(dir string, linkMode string) (*artifactCache, error) {
+	switch linkMode {
+	case "":
+		linkMode = cacheLinkModeCopy
+	case cacheLinkModeCopy, cacheLinkModeHardlink, cacheLinkModeSymlink:
+	default:
+		return nil, fmt.Errorf("unknown cache link mode %q, expected copy, hardlink or symlink", linkMode)
+	}
+
+	for _, sub := range []string{"blobs/sha256", "index"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, fmt.Errorf("could not create cache directory: %s", err)
+		}
+	}
+
+	return &artifactCache{dir: dir, linkMode: linkMode}, nil
+}
+
+func (c *artifactCache) blobPath(sum string) string {
+	return filepath.Join(c.dir, "blobs", "sha256", strings.ToLower(sum))
+}
+
+func (c *artifactCache) indexPath(url string) string {
+	key := sha256.Sum256([]byte(url))
+	return filepath.Join(c.dir, "index", hex.EncodeToString(key[:])+".json")
+}
+
+// lookup returns the blob for url, or for sum when a checksum is pinned. The
+// blob is re-hashed so a corrupted cache entry is never served.
+func (c *artifactCache) lookup(url string, sum string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+
+	if sum == "" {
+		entry, err := c.entry(url)
+		if err != nil {
+			return "", false
+		}
+		sum = entry.SHA256
+	}
+
+	blob := c.blobPath(sum)
+	actual, err := fileSHA256(blob)
+	if err != nil || actual != strings.ToLower(sum) {
+		return "", false
+	}
+
+	return blob, true
+}
+
+func (c *artifactCache) entry(url string) (*cacheEntry, error) {
+	if c == nil {
+		return nil, os.ErrNotExist
+	}
+
+	content, err := os.ReadFile(c.indexPath(url))
+	if err != nil {
+		return nil, err
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(content, &entry); err != nil {
+		return nil, err
+	}
+
+	if entry.URL != url {
+		return nil, errors.New("cache index collision")
+	}
+
+	return &entry, nil
+}
+
+// store copies filename into the cache and records it as the content of url.
+// store adds filename to the cache as the content of url, together with the
+// validators of header. A nil header keeps the validators already recorded
+// for the same content.
+func (c *artifactCache) store(url string, filename string, header http.Header) error {
+	sum, err := fileSHA256(filename)
+	if err != nil {
+		return err
+	}
+
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+
+	blob := c.blobPath(sum)
+	if _, err := os.Stat(blob); err != nil {
+		if err := copyFileAtomic(filename, blob); err != nil {
+			return err
+		}
+	}
+
+	entry := cacheEntry{
+		URL:    url,
+		SHA256: sum,
+		Size:   fi.Size(),
+	}
+	if header != nil {
+		entry.ETag = header.Get("ETag")
+		entry.LastModified = header.Get("Last-Modified")
+	} else if previous, err := c.entry(url); err == nil && previous.SHA256 == sum {
+		entry.ETag, entry.LastModified = previous.ETag, previous.LastModified
+	}
+
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(c.indexPath(url), content)
+}
+
+// restoreFromCache copies the cached artifact for url, or for the pinned
+// sha256 checksum, to filename.
+func restoreFromCache(cache *artifactCache, url string, sum string, filename string) error {
+	if cache == nil {
+		return errors.New("offline mode requires the provider cache_dir to be set")
+	}
+
+	blob, ok := cache.lookup(url, sum)
+	if !ok {
+		if sum != "" {
+			return fmt.Errorf("offline mode: artifact %s with sha256 %s is not in the cache %s", url, sum, cache.dir)
+		}
+		return fmt.Errorf("offline mode: artifact %s is not in the cache %s", url, cache.dir)
+	}
+
+	return cache.place(blob, filename)
+}
+
+// place puts a cached blob at filename according to the cache link mode.
+// Hard links fall back to a copy when the cache is on another file system.
+// An existing file is unlinked first so a link at filename is never written
+// through.
+func (c *artifactCache) place(blob string, filename string) error {
+	if err := os.Remove(filename); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if c.linkMode == cacheLinkModeCopy {
+		return copyFile(blob, filename)
+	}
+
+	if c.linkMode == cacheLinkModeSymlink {
+		target, err := filepath.Abs(blob)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, filename)
+	}
+
+	if err := os.Link(blob, filename); err != nil {
+		return copyFile(blob, filename)
+	}
+
+	return nil
+}
+
+func fileSHA256(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		err := f.Close()
+		if err != nil {
+			log.Printf("error closing file: %s", err)
+		}
+	}()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := in.Close()
+		if err != nil {
+			log.Printf("error closing file: %s", err)
+		}
+	}()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// writeAtomic writes the content produced by write to a temporary file next
+// to filename and renames it into place.
+func writeAtomic(filename string, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	err = write(tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), filename)
+	}
+
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+
+	return err
+}
+
+func copyFileAtomic(src string, dst string) error {
+	return writeAtomic(dst, func(w io.Writer) error {
+		in, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			err := in.Close()
+			if err != nil {
+				log.Printf("error closing file: %s", err)
+			}
+		}()
+
+		_, err = io.Copy(w, in)
+		return err
+	})
+}
+
+func writeFileAtomic(filename string, content []byte) error {
+	return writeAtomic(filename, func(w io.Writer) error {
+		_, err := w.Write(content)
+		return err
+	})
+}
@@ -0,0 +1,270 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const testContentSHA256 = "ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73"
+
+func testCache(t *testing.T) *artifactCache {
+	t.Helper()
+
+	cache, err := newArtifactCache(filepath.Join(t.TempDir(), "cache"), cacheLinkModeCopy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cache
+}
+
+func TestArtifactCache_StoreAndRestore(t *testing.T) {
+	cache := testCache(t)
+	dir := t.TempDir()
+
+	src := filepath.Join(dir, "src.dat")
+	if err := os.WriteFile(src, []byte("content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cache.store("https://example.com/file.dat", src, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tc := range map[string]struct{ url, sum string }{
+		"by url":    {url: "https://example.com/file.dat"},
+		"by sha256": {url: "https://mirror.example.com/other.dat", sum: testContentSHA256},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dst := filepath.Join(dir, name+".dat")
+			if err := restoreFromCache(cache, tc.url, tc.sum, dst); err != nil {
+				t.Fatal(err)
+			}
+
+			content, _ := os.ReadFile(dst)
+			if string(content) != "content" {
+				t.Errorf("unexpected content %q", content)
+			}
+		})
+	}
+}
+
+func TestArtifactCache_Missing(t *testing.T) {
+	cache := testCache(t)
+
+	err := restoreFromCache(cache, "https://example.com/file.dat", "", filepath.Join(t.TempDir(), "file.dat"))
+	if err == nil || !strings.Contains(err.Error(), "artifact https://example.com/file.dat is not in the cache") {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	err = restoreFromCache(cache, "https://example.com/file.dat", testContentSHA256, filepath.Join(t.TempDir(), "file.dat"))
+	if err == nil || !strings.Contains(err.Error(), "with sha256 "+testContentSHA256) {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	err = restoreFromCache(nil, "https://example.com/file.dat", "", filepath.Join(t.TempDir(), "file.dat"))
+	if err == nil || !strings.Contains(err.Error(), "cache_dir") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestArtifactCache_CorruptBlob(t *testing.T) {
+	cache := testCache(t)
+
+	src := filepath.Join(t.TempDir(), "src.dat")
+	if err := os.WriteFile(src, []byte("content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cache.store("https://example.com/file.dat", src, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(cache.blobPath(testContentSHA256), []byte("tampered"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := cache.lookup("https://example.com/file.dat", ""); ok {
+		t.Error("expected corrupt blob to be rejected")
+	}
+}
+
+func TestOfflineClient_NeverConnects(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer server.Close()
+
+	client := testClient(func(cfg *clientConfig) {
+		cfg.Offline = true
+	})
+
+	_, err := downloadFile(context.Background(), client, filepath.Join(t.TempDir(), "file.dat"), server.URL, requestOptions{})
+	if !errors.Is(err, errOffline) {
+		t.Errorf("expected offline error, got %v", err)
+	}
+
+	if _, err := getRemoteFileMetadata(context.Background(), client, server.URL, requestOptions{}); !errors.Is(err, errOffline) {
+		t.Errorf("expected offline error, got %v", err)
+	}
+
+	if requests.Load() != 0 {
+		t.Errorf("expected no requests, got %d", requests.Load())
+	}
+}
+
+func TestDownloadFileFunction_OfflineFromEnvironment(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	filename := filepath.Join(t.TempDir(), "file.dat")
+	args := []attr.Value{types.StringValue(server.URL), types.StringValue(filename), types.TupleValueMust([]attr.Type{}, []attr.Value{})}
+
+	t.Setenv("DOWNLOAD_OFFLINE", "true")
+	t.Setenv("DOWNLOAD_CACHE_DIR", "")
+	if err := runFileFunction(testFileFunction(), args...); err == nil || !strings.Contains(err.Error(), "DOWNLOAD_CACHE_DIR") {
+		t.Errorf("expected offline mode without a cache to fail, got %v", err)
+	}
+
+	cache := testCache(t)
+	src := filepath.Join(t.TempDir(), "src.dat")
+	if err := os.WriteFile(src, []byte("content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.store(server.URL, src, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("DOWNLOAD_CACHE_DIR", cache.dir)
+	if err := runFileFunction(testFileFunction(), args...); err != nil {
+		t.Fatal(err)
+	}
+
+	content, _ := os.ReadFile(filename)
+	if string(content) != "content" {
+		t.Errorf("expected the cached content, got %q", content)
+	}
+
+	if requests.Load() != 0 {
+		t.Errorf("expected no requests, got %d", requests.Load())
+	}
+}
+
+func TestArtifactCache_LinkModes(t *testing.T) {
+	for _, mode := range []string{cacheLinkModeCopy, cacheLinkModeHardlink, cacheLinkModeSymlink} {
+		t.Run(mode, func(t *testing.T) {
+			dir := t.TempDir()
+			cache, err := newArtifactCache(filepath.Join(dir, "cache"), mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			src := filepath.Join(dir, "src.dat")
+			if err := os.WriteFile(src, []byte("content"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := cache.store("https://example.com/file.dat", src, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("changed"))
+			}))
+			defer server.Close()
+
+			output := filepath.Join(dir, "output.dat")
+			if err := restoreFromCache(cache, "https://example.com/file.dat", testContentSHA256, output); err != nil {
+				t.Fatal(err)
+			}
+
+			fi, err := os.Lstat(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (fi.Mode()&os.ModeSymlink != 0) != (mode == cacheLinkModeSymlink) {
+				t.Errorf("unexpected file mode %s", fi.Mode())
+			}
+
+			// Downloading over a placed file must never modify the cache.
+			client := testClient()
+			if _, err := downloadFile(context.Background(), client, output, server.URL, requestOptions{}); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, ok := cache.lookup("", testContentSHA256); !ok {
+				t.Error("cache blob was modified through the output file")
+			}
+		})
+	}
+}
+
+func TestDownloadWithCache(t *testing.T) {
+	var gets atomic.Int32
+	var content, etag atomic.Value
+	content.Store("content")
+	etag.Store(`"v1"`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			gets.Add(1)
+		}
+		if v := etag.Load().(string); v != "" {
+			w.Header().Set("ETag", v)
+		}
+		_, _ = w.Write([]byte(content.Load().(string)))
+	}))
+	defer server.Close()
+
+	cache := testCache(t)
+	client := testClient()
+	dir := t.TempDir()
+
+	download := func(name string) string {
+		t.Helper()
+
+		remote, err := getRemoteFileMetadata(context.Background(), client, server.URL, requestOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		output := filepath.Join(dir, name)
+		if err := downloadWithCache(context.Background(), client, cache, output, server.URL, remote, requestOptions{}); err != nil {
+			t.Fatal(err)
+		}
+
+		got, _ := os.ReadFile(output)
+		return string(got)
+	}
+
+	download("first.dat")
+	if got := download("second.dat"); got != "content" || gets.Load() != 1 {
+		t.Errorf("expected the second call to be served from the cache, got %q after %d downloads", got, gets.Load())
+	}
+
+	// Same size, new ETag: the cached blob is stale.
+	content.Store("changed")
+	etag.Store(`"v2"`)
+	if got := download("third.dat"); got != "changed" || gets.Load() != 2 {
+		t.Errorf("expected a changed ETag to bypass the cache, got %q after %d downloads", got, gets.Load())
+	}
+
+	// Without a validator the size alone is not trusted.
+	etag.Store("")
+	download("fourth.dat")
+	if got := download("fifth.dat"); got != "changed" || gets.Load() != 4 {
+		t.Errorf("expected no cache hit without a validator, got %q after %d downloads", got, gets.Load())
+	}
+}
@@ -0,0 +1,361 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	defaultConnectTimeout        = 30 * time.Second
+	defaultTLSHandshakeTimeout   = 10 * time.Second
+	defaultResponseHeaderTimeout = 60 * time.Second
+	defaultIdleConnTimeout       = 90 * time.Second
+	defaultMaxIdleConns          = 100
+	defaultMaxIdleConnsPerHost   = 2
+)
+
+// clientConfig holds the settings used to build the HTTP client shared by
+// the data source and the file function.
+type clientConfig struct {
+	Version               string
+	UserAgent             string
+	Timeout               time.Duration
+	ConnectTimeout        time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	IdleConnTimeout       time.Duration
+	MaxIdleConns          int
+	MaxIdleConnsPerHost   int
+	MaxConnsPerHost       int
+	ProxyURL              *url.URL
+	ProxyUsername         string
+	ProxyPassword         string
+	NoProxy               []string
+	TLSConfig             *tls.Config
+	Retry                 retryPolicy
+	Credentials           []credential
+	Netrc                 []credential
+	URLRewrites           []urlRewrite
+	HostPolicy            hostPolicy
+	Offline               bool
+	Limiter               *transferLimiter
+	Resolver              resolverConfig
+	Redirects             redirectPolicy
+}
+
+func defaultClientConfig(version string) clientConfig {
+	return clientConfig{
+		Version:               version,
+		ConnectTimeout:        defaultConnectTimeout,
+		TLSHandshakeTimeout:   defaultTLSHandshakeTimeout,
+		ResponseHeaderTimeout: defaultResponseHeaderTimeout,
+		IdleConnTimeout:       defaultIdleConnTimeout,
+		MaxIdleConns:          defaultMaxIdleConns,
+		MaxIdleConnsPerHost:   defaultMaxIdleConnsPerHost,
+		Retry:                 defaultRetryPolicy(),
+		Redirects:             defaultRedirectPolicy(),
+	}
+}
+
+// downloadClient wraps the configured http.Client together with the
+// request defaults applied to every download.
+type downloadClient struct {
+	httpClient *http.Client
+	userAgent  string
+	redactor   secretRedactor
+	retry      retryPolicy
+	auth       *authTransport
+	rewrites   []urlRewrite
+	offline    bool
+	limiter    *transferLimiter
+}
+
+func newDownloadClient(cfg clientConfig) *downloadClient {
+	dialer := &net.Dialer{
+		Timeout:   cfg.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	dialer.Resolver = cfg.Resolver.netResolver(dialer)
+
+	dialContext := func(ctx context.Context, network, address string) (net.Conn, error) {
+		return cfg.Resolver.dial(ctx, dialer, network, address)
+	}
+	if cfg.HostPolicy.BlockPrivateAddresses {
+		dialContext = guardDialContext(dialer, proxyAddresses(cfg), cfg.Resolver.dial)
+	}
+
+	proxy := proxyFunc(cfg)
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialContext,
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	if cfg.TLSConfig != nil {
+		transport.TLSClientConfig = cfg.TLSConfig.Clone()
+	}
+
+	var auth *authTransport
+	var roundTripper http.RoundTripper = transport
+	if len(cfg.Credentials) > 0 || len(cfg.Netrc) > 0 {
+		auth = &authTransport{
+			base:        transport,
+			credentials: cfg.Credentials,
+			netrc:       cfg.Netrc,
+		}
+		roundTripper = auth
+	}
+
+	if cfg.HostPolicy.enabled() || cfg.HostPolicy.BlockPrivateAddresses {
+		roundTripper = &policyTransport{
+			base:     roundTripper,
+			policy:   cfg.HostPolicy,
+			proxy:    proxy,
+			resolver: cfg.Resolver,
+			lookup:   dialer.Resolver,
+		}
+	}
+
+	return &downloadClient{
+		httpClient: &http.Client{
+			Transport:     roundTripper,
+			CheckRedirect: cfg.Redirects.checkRedirect,
+			Timeout:       cfg.Timeout,
+		},
+		userAgent: buildUserAgent(cfg.UserAgent, cfg.Version),
+		redactor:  clientSecrets(cfg),
+		retry:     cfg.Retry,
+		auth:      auth,
+		rewrites:  cfg.URLRewrites,
+		offline:   cfg.Offline,
+		limiter:   cfg.Limiter,
+	}
+}
+
+func buildUserAgent(custom string, version string) string {
+	if version == "" {
+		version = "dev"
+	}
+
+	ua := fmt.Sprintf("terraform-provider-download/%s", version)
+	if custom != "" {
+		ua = fmt.Sprintf("%s %s", custom, ua)
+	}
+
+	return ua
+}
+
+// effectiveURL returns u after applying the provider URL rewrite rules.
+func (c *downloadClient) effectiveURL(ctx context.Context, u string) string {
+	rewritten := rewriteURL(c.rewrites, u)
+	if rewritten != u {
+		tflog.Info(ctx, "Rewrote download URL", map[string]interface{}{
+			"url":           u,
+			"effective_url": rewritten,
+		})
+	}
+
+	return rewritten
+}
+
+func (c *downloadClient) newRequest(ctx context.Context, method string, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", c.userAgent)
+
+	return req, nil
+}
+
+const statusBodySnippetLimit = 512
+
+// bodySnippet returns the beginning of body as printable text.
+func bodySnippet(body io.Reader) string {
+	if body == nil {
+		return ""
+	}
+
+	content, _ := io.ReadAll(io.LimitReader(body, statusBodySnippetLimit+1))
+	truncated := len(content) > statusBodySnippetLimit
+	if truncated {
+		content = content[:statusBodySnippetLimit]
+	}
+
+	snippet := strings.TrimSpace(strings.ToValidUTF8(string(content), ""))
+	if truncated {
+		snippet += "..."
+	}
+
+	return snippet
+}
+
+// requestOptions are the per download settings applied on top of the client
+// defaults.
+type requestOptions struct {
+	Method  string
+	Body    []byte
+	Headers map[string]string
+
+	// ExpectedStatusCodes are the response codes accepted for a download.
+	// Defaults to 200.
+	ExpectedStatusCodes []int
+
+	// MaxSize caps the size of the response body, when positive.
+	MaxSize int64
+
+	// RetryNonIdempotent allows retrying methods such as POST, which may
+	// have side effects on the server.
+	RetryNonIdempotent bool
+
+	// Validators make the download conditional. A 304 response then leaves
+	// the output file untouched.
+	Validators *fileValidators
+}
+
+const defaultMaxContentSize = 1 << 20
+
+func errContentTooLarge(max int64) error {
+	return fmt.Errorf("response body exceeds the maximum content size of %d bytes", max)
+}
+
+func (o requestOptions) method() string {
+	if o.Method == "" {
+		return http.MethodGet
+	}
+
+	return o.Method
+}
+
+func (o requestOptions) body() io.Reader {
+	if o.Body == nil {
+		return nil
+	}
+
+	return bytes.NewReader(o.Body)
+}
+
+func (o requestOptions) accepts(statusCode int) bool {
+	if len(o.ExpectedStatusCodes) == 0 {
+		return statusCode == http.StatusOK
+	}
+
+	return slices.Contains(o.ExpectedStatusCodes, statusCode)
+}
+
+// idempotent reports whether the method may be repeated safely (RFC 9110,
+// section 9.2.2).
+func (o requestOptions) idempotent() bool {
+	switch o.method() {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
+
+// cacheable reports whether the response may be served from and stored in
+// the artifact cache, which is keyed by URL only.
+func (o requestOptions) cacheable() bool {
+	return o.method() == http.MethodGet && o.Body == nil
+}
+
+// retryPolicy returns the policy of client, without retries for
+// non-idempotent requests unless they were explicitly allowed.
+func (o requestOptions) retryPolicy(ctx context.Context, client *downloadClient) retryPolicy {
+	policy := client.retry
+	if policy.MaxAttempts > 1 && !o.idempotent() && !o.RetryNonIdempotent {
+		tflog.Debug(ctx, "Not retrying non-idempotent request", map[string]interface{}{
+			"method": o.method(),
+		})
+		policy.MaxAttempts = 1
+	}
+
+	return policy
+}
+
+// requestHeadersKey is the context key of the names of the request headers
+// set by requestOptions, which checkRedirect drops once a redirect leaves
+// the original host.
+type requestHeadersKey struct{}
+
+// apply sets the headers and validators of o on req and returns it with the
+// header names recorded in its context.
+func (o requestOptions) apply(req *http.Request) *http.Request {
+	var names []string
+	for name, value := range o.Headers {
+		if strings.EqualFold(name, "Host") {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(name, value)
+		names = append(names, name)
+	}
+
+	o.Validators.apply(req)
+
+	if len(names) == 0 {
+		return req
+	}
+
+	return req.WithContext(context.WithValue(req.Context(), requestHeadersKey{}, names))
+}
+
+func (c *downloadClient) do(req *http.Request) (*http.Response, error) {
+	if c.offline {
+		return nil, errOffline
+	}
+
+	resp, err := c.httpClient.Do(req)
+	return resp, c.redactor.redact(err)
+}
+
+// credentialSources names the sources of the credentials sent with each
+// request of resp's redirect chain, without repeating any.
+func (c *downloadClient) credentialSources(resp *http.Response) []string {
+	var sources []string
+	for req := resp.Request; req != nil; {
+		if source := c.auth.describe(req.URL); source != "" && !slices.Contains(sources, source) {
+			sources = append([]string{source}, sources...)
+		}
+		if req.Response == nil {
+			break
+		}
+		req = req.Response.Request
+	}
+
+	return sources
+}
+
+// statusError builds the error for an unexpected response, naming the source
+// of the credentials when the server rejected them and quoting the start of
+// the response body.
+func (c *downloadClient) statusError(resp *http.Response) *statusError {
+	err := newStatusError(resp)
+	err.Body = c.redactor.redactString(bodySnippet(resp.Body))
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		err.CredentialSource = c.auth.describe(resp.Request.URL)
+	}
+
+	return err
+}
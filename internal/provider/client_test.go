@@ -0,0 +1,323 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testClient returns a client with the default configuration, changed by
+// each of configure.
+func testClient(configure ...func(cfg *clientConfig)) *downloadClient {
+	cfg := defaultClientConfig("dev")
+	for _, c := range configure {
+		c(&cfg)
+	}
+
+	return newDownloadClient(cfg)
+}
+
+func TestDownloadClient_UserAgent(t *testing.T) {
+	var userAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	client := testClient(func(cfg *clientConfig) {
+		cfg.Version = "1.2.3"
+		cfg.UserAgent = "acme-ci/7"
+	})
+
+	_, err := downloadFile(context.Background(), client, filepath.Join(t.TempDir(), "file.dat"), server.URL, requestOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if userAgent != "acme-ci/7 terraform-provider-download/1.2.3" {
+		t.Errorf("unexpected User-Agent %q", userAgent)
+	}
+}
+
+func TestDownloadClient_ResponseHeaderTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	client := testClient(func(cfg *clientConfig) {
+		cfg.ResponseHeaderTimeout = 50 * time.Millisecond
+	})
+
+	output := filepath.Join(t.TempDir(), "file.dat")
+	_, err := downloadFile(context.Background(), client, output, server.URL, requestOptions{})
+	if err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("expected no output file, got %v", err)
+	}
+}
+
+func TestDownloadFile_MethodAndBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_, _ = fmt.Fprintf(w, "%s %s %s", r.Method, r.Header.Get("Content-Type"), body)
+	}))
+	defer server.Close()
+
+	client := testClient()
+
+	data := DownloadFileDataSourceModel{
+		Method:            types.StringValue("post"),
+		RequestBodyBase64: types.StringValue(base64.StdEncoding.EncodeToString([]byte(`{"format":"tar"}`))),
+		RequestHeaders: types.MapValueMust(types.StringType, map[string]attr.Value{
+			"Content-Type": types.StringValue("application/json"),
+		}),
+		ExpectedStatusCodes: types.ListValueMust(types.Int64Type, []attr.Value{types.Int64Value(200), types.Int64Value(203)}),
+	}
+
+	var diags diag.Diagnostics
+	opts := data.requestOptions(context.Background(), &diags)
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+
+	output := filepath.Join(t.TempDir(), "file.dat")
+	if _, err := downloadFile(context.Background(), client, output, server.URL, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	content, _ := os.ReadFile(output)
+	if string(content) != `POST application/json {"format":"tar"}` {
+		t.Errorf("unexpected content %q", content)
+	}
+
+	if !opts.accepts(http.StatusNonAuthoritativeInfo) {
+		t.Errorf("expected 203 to be accepted, got %v", opts.ExpectedStatusCodes)
+	}
+
+	if opts.cacheable() {
+		t.Error("expected a POST download not to be cacheable")
+	}
+}
+
+func TestDownloadFileDataSourceModel_InvalidRequest(t *testing.T) {
+	for name, data := range map[string]DownloadFileDataSourceModel{
+		"method": {Method: types.StringValue("GET /")},
+		"both bodies": {
+			RequestBody:       types.StringValue("{}"),
+			RequestBodyBase64: types.StringValue("e30="),
+		},
+		"base64":      {RequestBodyBase64: types.StringValue("not base64")},
+		"status code": {ExpectedStatusCodes: types.ListValueMust(types.Int64Type, []attr.Value{types.Int64Value(99)})},
+	} {
+		var diags diag.Diagnostics
+		data.requestOptions(context.Background(), &diags)
+		if !diags.HasError() {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestDownloadFile_ResponseMetadata(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Last-Modified", "Wed, 21 Oct 2015 07:28:00 GMT")
+		w.Header().Set("X-Build-Id", "1234")
+		w.Header().Add("X-Tag", "a")
+		w.Header().Add("X-Tag", "b")
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	client := testClient()
+
+	result, err := downloadFile(context.Background(), client, filepath.Join(t.TempDir(), "file.dat"), server.URL, requestOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var data DownloadFileDataSourceModel
+	data.setResponse(result)
+
+	if data.StatusCode.ValueInt64() != http.StatusOK {
+		t.Errorf("unexpected status code %s", data.StatusCode)
+	}
+	if data.ContentType.ValueString() != "application/gzip" {
+		t.Errorf("unexpected content type %s", data.ContentType)
+	}
+	if data.LastModified.ValueString() != "Wed, 21 Oct 2015 07:28:00 GMT" {
+		t.Errorf("unexpected last modified %s", data.LastModified)
+	}
+
+	headers := data.ResponseHeaders.Elements()
+	if headers["X-Build-Id"] != types.StringValue("1234") || headers["X-Tag"] != types.StringValue("a, b") {
+		t.Errorf("unexpected response headers %v", headers)
+	}
+}
+
+func TestDownloadFile_ExpectedStatusCodes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/denied" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = fmt.Fprintf(w, "access denied for token secret-token\n%s", strings.Repeat("x", 1024))
+			return
+		}
+		w.WriteHeader(http.StatusNonAuthoritativeInfo)
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	client := testClient(func(cfg *clientConfig) {
+		cfg.Credentials = []credential{{Host: "example.com", Type: credentialTypeBearer, Token: "secret-token"}}
+	})
+
+	output := filepath.Join(t.TempDir(), "file.dat")
+
+	_, err := downloadFile(context.Background(), client, output, server.URL, requestOptions{})
+	if err == nil || !strings.Contains(err.Error(), `bad status: 203 Non-Authoritative Information, response body: "content"`) {
+		t.Errorf("expected 203 to be rejected by default, got %v", err)
+	}
+
+	opts := requestOptions{ExpectedStatusCodes: []int{http.StatusOK, http.StatusNonAuthoritativeInfo}}
+	if _, err := downloadFile(context.Background(), client, output, server.URL, opts); err != nil {
+		t.Errorf("expected 203 to be accepted, got %v", err)
+	}
+
+	_, err = downloadFile(context.Background(), client, output, server.URL+"/denied", opts)
+	if err == nil {
+		t.Fatal("expected 403 to be rejected")
+	}
+
+	msg := err.Error()
+	if !strings.Contains(msg, `access denied for token REDACTED\n`) || !strings.HasSuffix(msg, `..."`) || len(msg) > 700 {
+		t.Errorf("expected a redacted and truncated body snippet, got %s", msg)
+	}
+}
+
+func TestDownloadFile_MaxSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chunked" {
+			w.(http.Flusher).Flush()
+		}
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	client := testClient()
+
+	for _, path := range []string{"/", "/chunked"} {
+		output := filepath.Join(t.TempDir(), "file.dat")
+
+		_, err := downloadFile(context.Background(), client, output, server.URL+path, requestOptions{MaxSize: 6})
+		if err == nil || !strings.Contains(err.Error(), "exceeds the maximum content size of 6 bytes") {
+			t.Errorf("%s: expected size error, got %v", path, err)
+		}
+
+		if _, err := downloadFile(context.Background(), client, output, server.URL+path, requestOptions{MaxSize: 7}); err != nil {
+			t.Errorf("%s: unexpected error %v", path, err)
+		}
+	}
+}
+
+func TestDownloadFileDataSourceModel_Content(t *testing.T) {
+	data := DownloadFileDataSourceModel{OutputFile: types.StringNull()}
+	if !data.includeContent() {
+		t.Error("expected content to be included without output_file")
+	}
+
+	data.OutputFile = types.StringValue("file.dat")
+	if data.includeContent() {
+		t.Error("expected content to be omitted with output_file")
+	}
+
+	data.setContent([]byte("content"))
+	if data.Content.ValueString() != "content" || data.ContentBase64.ValueString() != "Y29udGVudA==" {
+		t.Errorf("unexpected content %s, %s", data.Content, data.ContentBase64)
+	}
+
+	data = DownloadFileDataSourceModel{ContentSensitive: types.BoolValue(true)}
+	data.setContent([]byte{0xff, 0xfe})
+	if !data.SensitiveContent.IsNull() || data.SensitiveContentBase64.ValueString() != "//4=" || !data.Content.IsNull() {
+		t.Errorf("unexpected sensitive content %s, %s", data.SensitiveContent, data.SensitiveContentBase64)
+	}
+}
+
+func TestFileMatchesSHA256(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "file.dat")
+	sum := "ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73"
+
+	if fileMatchesSHA256(filename, sum) {
+		t.Error("expected a missing file not to match")
+	}
+
+	if err := os.WriteFile(filename, []byte("content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if !fileMatchesSHA256(filename, sum) {
+		t.Error("expected the file to match its checksum")
+	}
+
+	if fileMatchesSHA256(filename, strings.ToUpper(sum)) {
+		t.Error("expected the comparison to be as strict as verify_sha256")
+	}
+}
+
+func TestDownloadFileDataSource_DerivedClients(t *testing.T) {
+	cfg := defaultClientConfig("dev")
+	client := newDownloadClient(cfg)
+	f := &DownloadFileDataSource{providerData: &providerData{config: cfg, client: client}}
+
+	derived := func(data DownloadFileDataSourceModel) *downloadClient {
+		t.Helper()
+
+		var diags diag.Diagnostics
+		client, err := f.client(&data, &diags)
+		if err != nil || diags.HasError() {
+			t.Fatal(err, diags)
+		}
+		return client
+	}
+
+	if derived(DownloadFileDataSourceModel{}) != client {
+		t.Error("expected the provider client without overrides")
+	}
+
+	tls13 := DownloadFileDataSourceModel{TLS: &TLSModel{MinVersion: types.StringValue("1.3")}}
+	first := derived(tls13)
+	if first == client || derived(tls13) != first {
+		t.Error("expected the same overrides to share a dedicated client")
+	}
+
+	if derived(DownloadFileDataSourceModel{TLS: &TLSModel{MinVersion: types.StringValue("1.2")}}) == first {
+		t.Error("expected other tls settings to get another client")
+	}
+
+	if derived(DownloadFileDataSourceModel{TLS: tls13.TLS, MaxRedirects: types.Int64Value(1)}) == first {
+		t.Error("expected other redirect settings to get another client")
+	}
+
+	if _, err := f.client(&DownloadFileDataSourceModel{TLS: &TLSModel{MinVersion: types.StringValue("0.9")}}, &diag.Diagnostics{}); err == nil {
+		t.Error("expected an invalid tls configuration to fail")
+	}
+	if len(f.providerData.derived) != 3 {
+		t.Errorf("expected 3 dedicated clients, got %d", len(f.providerData.derived))
+	}
+}
@@ -0,0 +1,406 @@
+package provider
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	credentialTypeBasic  = "basic"
+	credentialTypeBearer = "bearer"
+	credentialTypeDigest = "digest"
+	credentialTypeHeader = "header"
+)
+
+type CredentialModel struct {
+	Host        types.String `tfsdk:"host"`
+	PathPrefix  types.String `tfsdk:"path_prefix"`
+	Type        types.String `tfsdk:"type"`
+	Username    types.String `tfsdk:"username"`
+	Password    types.String `tfsdk:"password"`
+	Token       types.String `tfsdk:"token"`
+	HeaderName  types.String `tfsdk:"header_name"`
+	HeaderValue types.String `tfsdk:"header_value"`
+}
+
+func providerCredentialsBlock() schema.ListNestedBlock {
+	return schema.ListNestedBlock{
+		MarkdownDescription: "Credentials sent to a specific host. When several blocks match a request, the one with the longest `path_prefix` is used.",
+		NestedObject: schema.NestedBlockObject{
+			Attributes: map[string]schema.Attribute{
+				"host": schema.StringAttribute{
+					MarkdownDescription: "Host name the credentials are sent to. Include the port (`example.com:8443`) to match only that port.",
+					Required:            true,
+				},
+				"path_prefix": schema.StringAttribute{
+					MarkdownDescription: "Only send the credentials for request paths starting with this prefix.",
+					Optional:            true,
+				},
+				"type": schema.StringAttribute{
+					MarkdownDescription: "Authentication scheme: `basic`, `bearer`, `digest` or `header`.",
+					Required:            true,
+				},
+				"username": schema.StringAttribute{
+					MarkdownDescription: "Username for `basic` and `digest` authentication.",
+					Optional:            true,
+				},
+				"password": schema.StringAttribute{
+					MarkdownDescription: "Password for `basic` and `digest` authentication.",
+					Optional:            true,
+					Sensitive:           true,
+				},
+				"token": schema.StringAttribute{
+					MarkdownDescription: "Token for `bearer` authentication.",
+					Optional:            true,
+					Sensitive:           true,
+				},
+				"header_name": schema.StringAttribute{
+					MarkdownDescription: "Header name for `header` authentication (e.g. `X-JFrog-Art-Api`).",
+					Optional:            true,
+				},
+				"header_value": schema.StringAttribute{
+					MarkdownDescription: "Header value for `header` authentication.",
+					Optional:            true,
+					Sensitive:           true,
+				},
+			},
+		},
+	}
+}
+
+type credential struct {
+	Host        string
+	PathPrefix  string
+	Type        string
+	Username    string
+	Password    string
+	Token       string
+	HeaderName  string
+	HeaderValue string
+	Source      string
+}
+
+func credentialsFromModel(models []CredentialModel, diags *diag.Diagnostics) []credential {
+	var credentials []credential
+
+	for i, m := range models {
+		p := path.Root("credentials").AtListIndex(i)
+		c := credential{
+			Host:        strings.ToLower(m.Host.ValueString()),
+			PathPrefix:  m.PathPrefix.ValueString(),
+			Type:        m.Type.ValueString(),
+			Username:    m.Username.ValueString(),
+			Password:    m.Password.ValueString(),
+			Token:       m.Token.ValueString(),
+			HeaderName:  m.HeaderName.ValueString(),
+			HeaderValue: m.HeaderValue.ValueString(),
+			Source:      fmt.Sprintf("provider credentials block for %s", m.Host.ValueString()),
+		}
+
+		if c.Host == "" {
+			diags.AddAttributeError(p.AtName("host"), "Invalid credentials", "host must not be empty.")
+		}
+
+		switch c.Type {
+		case credentialTypeBasic, credentialTypeDigest:
+			if c.Username == "" {
+				diags.AddAttributeError(p.AtName("username"), "Invalid credentials",
+					fmt.Sprintf("username is required for %s authentication.", c.Type))
+			}
+		case credentialTypeBearer:
+			if c.Token == "" {
+				diags.AddAttributeError(p.AtName("token"), "Invalid credentials", "token is required for bearer authentication.")
+			}
+		case credentialTypeHeader:
+			if c.HeaderName == "" || c.HeaderValue == "" {
+				diags.AddAttributeError(p.AtName("header_name"), "Invalid credentials",
+					"header_name and header_value are required for header authentication.")
+			}
+		default:
+			diags.AddAttributeError(p.AtName("type"), "Invalid credentials",
+				fmt.Sprintf("Unknown type %q, expected basic, bearer, digest or header.", c.Type))
+		}
+
+		credentials = append(credentials, c)
+	}
+
+	return credentials
+}
+
+func (c credential) matches(u *url.URL) bool {
+	if strings.Contains(c.Host, ":") {
+		if c.Host != strings.ToLower(u.Host) {
+			return false
+		}
+	} else if c.Host != strings.ToLower(u.Hostname()) {
+		return false
+	}
+
+	return strings.HasPrefix(u.Path, c.PathPrefix)
+}
+
+func (c credential) secrets() []string {
+	return []string{c.Password, c.Token, c.HeaderValue}
+}
+
+// authTransport adds credentials to outgoing requests. The credentials are
+// looked up for every request URL, including each redirect hop, so they are
+// only ever sent to the host they were configured for. Explicit credentials
+// take precedence over netrc entries.
+type authTransport struct {
+	base        http.RoundTripper
+	credentials []credential
+	netrc       []credential
+}
+
+func (t *authTransport) lookup(u *url.URL) *credential {
+	var match *credential
+	for i := range t.credentials {
+		c := &t.credentials[i]
+		if c.matches(u) && (match == nil || len(c.PathPrefix) > len(match.PathPrefix)) {
+			match = c
+		}
+	}
+
+	if match != nil {
+		return match
+	}
+
+	for i := range t.netrc {
+		c := &t.netrc[i]
+		if c.Host == strings.ToLower(u.Hostname()) {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// describe names the source of the credentials used for u, if any.
+func (t *authTransport) describe(u *url.URL) string {
+	if t == nil || u == nil {
+		return ""
+	}
+
+	if c := t.lookup(u); c != nil {
+		return c.Source
+	}
+
+	return ""
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c := t.lookup(req.URL)
+	if c == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	tflog.Debug(req.Context(), "Applying credentials", map[string]interface{}{
+		"host":   req.URL.Host,
+		"type":   c.Type,
+		"source": c.Source,
+	})
+
+	if c.Type == credentialTypeDigest {
+		return t.roundTripDigest(req, c)
+	}
+
+	req = req.Clone(req.Context())
+	switch c.Type {
+	case credentialTypeBasic:
+		req.SetBasicAuth(c.Username, c.Password)
+	case credentialTypeBearer:
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	case credentialTypeHeader:
+		req.Header.Set(c.HeaderName, c.HeaderValue)
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+// roundTripDigest sends the request unauthenticated and answers a digest
+// challenge (RFC 7616) when the server returns one.
+func (t *authTransport) roundTripDigest(req *http.Request, c *credential) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	challenge, ok := parseDigestChallenge(resp.Header.Values("WWW-Authenticate"))
+	if !ok {
+		return resp, nil
+	}
+
+	retry := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return resp, nil
+		}
+
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		retry.Body = body
+	}
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	authorization, err := challenge.authorize(req.Method, req.URL.RequestURI(), c.Username, c.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	retry.Header.Set("Authorization", authorization)
+
+	return t.base.RoundTrip(retry)
+}
+
+type digestChallenge struct {
+	params map[string]string
+}
+
+func parseDigestChallenge(headers []string) (*digestChallenge, bool) {
+	for _, header := range headers {
+		scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+		if !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+
+		return &digestChallenge{params: parseAuthParams(rest)}, true
+	}
+
+	return nil, false
+}
+
+func parseAuthParams(s string) map[string]string {
+	params := map[string]string{}
+
+	for s != "" {
+		s = strings.TrimLeft(s, " ,")
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+
+		key = strings.ToLower(strings.TrimSpace(key))
+		rest = strings.TrimLeft(rest, " ")
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			var b strings.Builder
+			i := 1
+			for ; i < len(rest); i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+					b.WriteByte(rest[i])
+					continue
+				}
+				if rest[i] == '"' {
+					break
+				}
+				b.WriteByte(rest[i])
+			}
+			value = b.String()
+			s = rest[min(i+1, len(rest)):]
+		} else {
+			value, s, _ = strings.Cut(rest, ",")
+			value = strings.TrimSpace(value)
+		}
+
+		params[key] = value
+	}
+
+	return params
+}
+
+func (d *digestChallenge) authorize(method string, uri string, username string, password string) (string, error) {
+	algorithm := d.params["algorithm"]
+	if algorithm == "" {
+		algorithm = "MD5"
+	}
+
+	var newHash func() hash.Hash
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "MD5":
+		newHash = md5.New
+	case "SHA-256":
+		newHash = sha256.New
+	default:
+		return "", fmt.Errorf("unsupported digest algorithm %q", algorithm)
+	}
+
+	h := func(s string) string {
+		hh := newHash()
+		hh.Write([]byte(s))
+		return hex.EncodeToString(hh.Sum(nil))
+	}
+
+	realm := d.params["realm"]
+	nonce := d.params["nonce"]
+
+	cnonceBytes := make([]byte, 16)
+	if _, err := rand.Read(cnonceBytes); err != nil {
+		return "", err
+	}
+	cnonce := hex.EncodeToString(cnonceBytes)
+	nc := "00000001"
+
+	ha1 := h(username + ":" + realm + ":" + password)
+	if strings.HasSuffix(strings.ToUpper(algorithm), "-SESS") {
+		ha1 = h(ha1 + ":" + nonce + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+
+	qop := ""
+	for _, option := range strings.Split(d.params["qop"], ",") {
+		if strings.TrimSpace(option) == "auth" {
+			qop = "auth"
+		}
+	}
+
+	if d.params["qop"] != "" && qop == "" {
+		return "", errors.New("digest challenge does not offer qop=auth")
+	}
+
+	var response string
+	if qop != "" {
+		response = h(strings.Join([]string{ha1, nonce, nc, cnonce, qop, ha2}, ":"))
+	} else {
+		response = h(ha1 + ":" + nonce + ":" + ha2)
+	}
+
+	parts := []string{
+		fmt.Sprintf("username=%q", username),
+		fmt.Sprintf("realm=%q", realm),
+		fmt.Sprintf("nonce=%q", nonce),
+		fmt.Sprintf("uri=%q", uri),
+		fmt.Sprintf("algorithm=%s", algorithm),
+		fmt.Sprintf("response=%q", response),
+	}
+
+	if qop != "" {
+		parts = append(parts, "qop="+qop, "nc="+nc, fmt.Sprintf("cnonce=%q", cnonce))
+	}
+
+	if opaque, ok := d.params["opaque"]; ok {
+		parts = append(parts, fmt.Sprintf("opaque=%q", opaque))
+	}
+
+	return "Digest " + strings.Join(parts, ", "), nil
+}
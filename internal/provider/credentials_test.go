@@ -0,0 +1,177 @@
+package provider
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCredentials_Schemes(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	cases := map[string]struct {
+		credential credential
+		path       string
+		header     string
+		expected   string
+	}{
+		"basic": {
+			credential: credential{Host: "127.0.0.1", Type: credentialTypeBasic, Username: "ci", Password: "pw"},
+			header:     "Authorization",
+			expected:   "Basic Y2k6cHc=",
+		},
+		"bearer with port": {
+			credential: credential{Host: host, Type: credentialTypeBearer, Token: "t0k3n"},
+			header:     "Authorization",
+			expected:   "Bearer t0k3n",
+		},
+		"header": {
+			credential: credential{Host: "127.0.0.1", Type: credentialTypeHeader, HeaderName: "X-JFrog-Art-Api", HeaderValue: "key"},
+			header:     "X-JFrog-Art-Api",
+			expected:   "key",
+		},
+		"path prefix mismatch": {
+			credential: credential{Host: "127.0.0.1", PathPrefix: "/private/", Type: credentialTypeBearer, Token: "t0k3n"},
+			path:       "/public/file.dat",
+			header:     "Authorization",
+			expected:   "",
+		},
+		"other host": {
+			credential: credential{Host: "artifacts.example.com", Type: credentialTypeBearer, Token: "t0k3n"},
+			header:     "Authorization",
+			expected:   "",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			client := testClient(func(cfg *clientConfig) {
+				cfg.Credentials = []credential{tc.credential}
+			})
+			_, err := downloadFile(context.Background(), client, filepath.Join(t.TempDir(), "file.dat"), server.URL+tc.path, requestOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got.Get(tc.header) != tc.expected {
+				t.Errorf("expected %s %q, got %q", tc.header, tc.expected, got.Get(tc.header))
+			}
+		})
+	}
+}
+
+func TestCredentials_LongestPathPrefixWins(t *testing.T) {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	client := testClient(func(cfg *clientConfig) {
+		cfg.Credentials = []credential{
+			{Host: "127.0.0.1", Type: credentialTypeBearer, Token: "host"},
+			{Host: "127.0.0.1", PathPrefix: "/team/", Type: credentialTypeBearer, Token: "team"},
+		}
+	})
+
+	_, err := downloadFile(context.Background(), client, filepath.Join(t.TempDir(), "file.dat"), server.URL+"/team/file.dat", requestOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if authorization != "Bearer team" {
+		t.Errorf("unexpected Authorization %q", authorization)
+	}
+}
+
+func TestCredentials_NotSentAfterCrossHostRedirect(t *testing.T) {
+	var leaked string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer other.Close()
+
+	// Same listener, different host name.
+	redirectTarget := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t0k3n" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.Redirect(w, r, redirectTarget+"/file.dat", http.StatusFound)
+	}))
+	defer origin.Close()
+
+	client := testClient(func(cfg *clientConfig) {
+		cfg.Credentials = []credential{{Host: "127.0.0.1", Type: credentialTypeBearer, Token: "t0k3n"}}
+	})
+
+	_, err := downloadFile(context.Background(), client, filepath.Join(t.TempDir(), "file.dat"), origin.URL, requestOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if leaked != "" {
+		t.Errorf("credentials were sent to the redirect target: %q", leaked)
+	}
+}
+
+func TestCredentials_Digest(t *testing.T) {
+	const realm, nonce = "artifacts", "dcd98b7102dd2f0e8b11d0f600bfb0c093"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		challenge, ok := parseDigestChallenge(r.Header.Values("Authorization"))
+		if !ok {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm=%q, qop="auth,auth-int", nonce=%q, opaque="5ccc"`, realm, nonce))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		p := challenge.params
+		ha1 := testMD5("ci:" + realm + ":secret")
+		ha2 := testMD5(r.Method + ":" + p["uri"])
+		expected := testMD5(strings.Join([]string{ha1, nonce, p["nc"], p["cnonce"], p["qop"], ha2}, ":"))
+		if p["response"] != expected || p["opaque"] != "5ccc" || p["uri"] != r.URL.RequestURI() {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	client := testClient(func(cfg *clientConfig) {
+		cfg.Credentials = []credential{{Host: "127.0.0.1", Type: credentialTypeDigest, Username: "ci", Password: "secret"}}
+	})
+
+	output := filepath.Join(t.TempDir(), "file.dat")
+	_, err := downloadFile(context.Background(), client, output, server.URL+"/file.dat?v=1", requestOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, _ := os.ReadFile(output)
+	if string(content) != "content" {
+		t.Errorf("unexpected content %q", content)
+	}
+}
+
+func testMD5(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -9,20 +9,28 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 var _ datasource.DataSource = &DownloadFileDataSource{}
+var _ datasource.DataSourceWithConfigure = &DownloadFileDataSource{}
 
 type DownloadFileDataSource struct {
+	providerData *providerData
 }
 
 func NewDownloadFileDataSource() datasource.DataSource {
@@ -30,17 +38,45 @@ func NewDownloadFileDataSource() datasource.DataSource {
 }
 
 type DownloadFileDataSourceModel struct {
-	Id           types.String `tfsdk:"id"`
-	Url          types.String `tfsdk:"url"`
-	OutputFile   types.String `tfsdk:"output_file"`
-	Base64SHA256 types.String `tfsdk:"output_base64sha256"`
-	MD5          types.String `tfsdk:"output_md5"`
-	SHA          types.String `tfsdk:"output_sha"`
-	SHA256       types.String `tfsdk:"output_sha256"`
-	FileSize     types.Int64  `tfsdk:"output_size"`
-	VerifySHA256 types.String `tfsdk:"verify_sha256"`
-	VerifySHA    types.String `tfsdk:"verify_sha"`
-	VerifyMD5    types.String `tfsdk:"verify_md5"`
+	Id                      types.String `tfsdk:"id"`
+	Url                     types.String `tfsdk:"url"`
+	EffectiveURL            types.String `tfsdk:"effective_url"`
+	FinalURL                types.String `tfsdk:"final_url"`
+	RedirectChain           types.List   `tfsdk:"redirect_chain"`
+	ResponseHeaders         types.Map    `tfsdk:"response_headers"`
+	StatusCode              types.Int64  `tfsdk:"status_code"`
+	ContentType             types.String `tfsdk:"content_type"`
+	LastModified            types.String `tfsdk:"last_modified"`
+	Downloaded              types.Bool   `tfsdk:"downloaded"`
+	OutputFile              types.String `tfsdk:"output_file"`
+	Base64SHA256            types.String `tfsdk:"output_base64sha256"`
+	MD5                     types.String `tfsdk:"output_md5"`
+	SHA                     types.String `tfsdk:"output_sha"`
+	SHA256                  types.String `tfsdk:"output_sha256"`
+	FileSize                types.Int64  `tfsdk:"output_size"`
+	VerifySHA256            types.String `tfsdk:"verify_sha256"`
+	VerifySHA               types.String `tfsdk:"verify_sha"`
+	VerifyMD5               types.String `tfsdk:"verify_md5"`
+	MaxRedirects            types.Int64  `tfsdk:"max_redirects"`
+	AllowInsecureRedirects  types.Bool   `tfsdk:"allow_insecure_redirects"`
+	AllowCrossHostRedirects types.Bool   `tfsdk:"allow_cross_host_redirects"`
+	RequestHeaders          types.Map    `tfsdk:"request_headers"`
+	Method                  types.String `tfsdk:"method"`
+	RequestBody             types.String `tfsdk:"request_body"`
+	RequestBodyBase64       types.String `tfsdk:"request_body_base64"`
+	RetryNonIdempotent      types.Bool   `tfsdk:"retry_non_idempotent"`
+	ExpectedStatusCodes     types.List   `tfsdk:"expected_status_codes"`
+	IncludeContent          types.Bool   `tfsdk:"include_content"`
+	FilePermission          types.String `tfsdk:"file_permission"`
+	DirectoryPermission     types.String `tfsdk:"directory_permission"`
+	CreateDirectories       types.Bool   `tfsdk:"create_directories"`
+	MaxContentSize          types.Int64  `tfsdk:"max_content_size"`
+	ContentSensitive        types.Bool   `tfsdk:"content_sensitive"`
+	Content                 types.String `tfsdk:"content"`
+	ContentBase64           types.String `tfsdk:"content_base64"`
+	SensitiveContent        types.String `tfsdk:"sensitive_content"`
+	SensitiveContentBase64  types.String `tfsdk:"sensitive_content_base64"`
+	TLS                     *TLSModel    `tfsdk:"tls"`
 }
 
 func (f *DownloadFileDataSource) Metadata(ctx context.Context, request datasource.MetadataRequest, response *datasource.MetadataResponse) {
@@ -55,9 +91,85 @@ func (f *DownloadFileDataSource) Schema(ctx context.Context, request datasource.
 				MarkdownDescription: "URL to download",
 				Required:            true,
 			},
+			"effective_url": schema.StringAttribute{
+				MarkdownDescription: "URL requested after applying the provider `url_rewrite` rules",
+				Computed:            true,
+			},
+			"final_url": schema.StringAttribute{
+				MarkdownDescription: "URL the content was downloaded from after following redirects",
+				Computed:            true,
+			},
+			"redirect_chain": schema.ListAttribute{
+				MarkdownDescription: "URLs that answered with a redirect, in the order they were requested",
+				ElementType:         types.StringType,
+				Computed:            true,
+			},
+			"response_headers": schema.MapAttribute{
+				MarkdownDescription: "Headers of the download response. Repeated headers are joined with `, `. After a `304 Not Modified` revalidation they are the headers of the response the file was downloaded from. Empty when the file was served from the provider cache.",
+				ElementType:         types.StringType,
+				Computed:            true,
+			},
+			"status_code": schema.Int64Attribute{
+				MarkdownDescription: "HTTP status code of the download response. After a `304 Not Modified` revalidation it is the status code of the response the file was downloaded from. Null when the file was served from the provider cache.",
+				Computed:            true,
+			},
+			"downloaded": schema.BoolAttribute{
+				MarkdownDescription: "Whether the file was transferred over the network. False when it was served from the provider cache, `output_file` already matched `verify_sha256`, or the server answered `304 Not Modified` to a request revalidating the previous download.",
+				Computed:            true,
+			},
+			"content_type": schema.StringAttribute{
+				MarkdownDescription: "`Content-Type` header of the download response",
+				Computed:            true,
+			},
+			"last_modified": schema.StringAttribute{
+				MarkdownDescription: "`Last-Modified` header of the download response",
+				Computed:            true,
+			},
 			"output_file": schema.StringAttribute{
-				MarkdownDescription: "File name to write content",
-				Required:            true,
+				MarkdownDescription: "File name to write content. When omitted the content is only returned in `content` and `content_base64`. The file is only replaced once the download is complete and verified. An interrupted download is kept as a hidden `.<name>.part` file and resumed with a `Range` request when the server supports it. The `ETag` and `Last-Modified` validators and the headers of the response are kept in a hidden `.<name>.validators.json` file next to it, and later downloads are sent as conditional requests.",
+				Optional:            true,
+			},
+			"file_permission": schema.StringAttribute{
+				MarkdownDescription: "Octal permission of the output file, e.g. `0755` for executables. A file whose mode drifted is corrected on the next read. Defaults to the mode of a newly created file (`0666` less the umask).",
+				Optional:            true,
+			},
+			"directory_permission": schema.StringAttribute{
+				MarkdownDescription: "Octal permission of directories created by `create_directories`. Defaults to `0777` less the umask.",
+				Optional:            true,
+			},
+			"create_directories": schema.BoolAttribute{
+				MarkdownDescription: "Create missing parent directories of `output_file`. Defaults to `false`.",
+				Optional:            true,
+			},
+			"include_content": schema.BoolAttribute{
+				MarkdownDescription: "Return the downloaded body in `content` and `content_base64`. Defaults to `true` when `output_file` is omitted and `false` otherwise.",
+				Optional:            true,
+			},
+			"max_content_size": schema.Int64Attribute{
+				MarkdownDescription: fmt.Sprintf("Maximum size in bytes of a body returned in `content`. Larger responses fail the download, `0` disables the limit. Defaults to `%d`.", defaultMaxContentSize),
+				Optional:            true,
+			},
+			"content_sensitive": schema.BoolAttribute{
+				MarkdownDescription: "Return the body in `sensitive_content` and `sensitive_content_base64` instead, hiding it from plan output. Defaults to `false`.",
+				Optional:            true,
+			},
+			"content": schema.StringAttribute{
+				MarkdownDescription: "Downloaded body as a string, when `include_content` is enabled and the body is valid UTF-8",
+				Computed:            true,
+			},
+			"content_base64": schema.StringAttribute{
+				MarkdownDescription: "Base64 encoded downloaded body, when `include_content` is enabled",
+				Computed:            true,
+			},
+			"sensitive_content": schema.StringAttribute{
+				MarkdownDescription: "Same as `content`, used when `content_sensitive` is enabled",
+				Computed:            true,
+				Sensitive:           true,
+			},
+			"sensitive_content_base64": schema.StringAttribute{
+				MarkdownDescription: "Same as `content_base64`, used when `content_sensitive` is enabled",
+				Computed:            true,
+				Sensitive:           true,
 			},
 			"output_base64sha256": schema.StringAttribute{
 				MarkdownDescription: "Base64 Encoded SHA256 checksum of output file",
@@ -91,12 +203,69 @@ func (f *DownloadFileDataSource) Schema(ctx context.Context, request datasource.
 				MarkdownDescription: "MD5 checksum to verify",
 				Optional:            true,
 			},
+			"request_headers": schema.MapAttribute{
+				MarkdownDescription: "HTTP headers sent with the request, such as `Accept` or `Authorization`. Headers set by the provider credentials take precedence for `Authorization`. They are only sent to the host of `url` and dropped once a redirect leaves it.",
+				ElementType:         types.StringType,
+				Optional:            true,
+				Sensitive:           true,
+			},
+			"method": schema.StringAttribute{
+				MarkdownDescription: "HTTP method of the request, e.g. `POST` for services that generate the file on demand. Defaults to `GET`. Only `GET` downloads without a request body use the provider cache.",
+				Optional:            true,
+			},
+			"request_body": schema.StringAttribute{
+				MarkdownDescription: "Body sent with the request. Conflicts with `request_body_base64`.",
+				Optional:            true,
+			},
+			"request_body_base64": schema.StringAttribute{
+				MarkdownDescription: "Base64 encoded binary body sent with the request. Conflicts with `request_body`.",
+				Optional:            true,
+			},
+			"expected_status_codes": schema.ListAttribute{
+				MarkdownDescription: "HTTP status codes accepted for the download, e.g. `[200, 203]`. Other codes fail with an error quoting the start of the response body. Defaults to `[200]`.",
+				ElementType:         types.Int64Type,
+				Optional:            true,
+			},
+			"retry_non_idempotent": schema.BoolAttribute{
+				MarkdownDescription: "Apply the provider `retry` policy to non-idempotent methods such as `POST` and `PATCH`, which are otherwise attempted only once. Defaults to `false`.",
+				Optional:            true,
+			},
+			"max_redirects": schema.Int64Attribute{
+				MarkdownDescription: "Maximum number of redirects to follow. Overrides the provider setting.",
+				Optional:            true,
+			},
+			"allow_insecure_redirects": schema.BoolAttribute{
+				MarkdownDescription: "Follow redirects from `https` to `http`. Overrides the provider setting.",
+				Optional:            true,
+			},
+			"allow_cross_host_redirects": schema.BoolAttribute{
+				MarkdownDescription: "Follow redirects to a different host. Overrides the provider setting.",
+				Optional:            true,
+			},
 			"id": schema.StringAttribute{
 				MarkdownDescription: "Identifier",
 				Computed:            true,
 			},
 		},
+		Blocks: map[string]schema.Block{
+			"tls": dataSourceTLSBlock(),
+		},
+	}
+}
+
+func (f *DownloadFileDataSource) Configure(ctx context.Context, request datasource.ConfigureRequest, response *datasource.ConfigureResponse) {
+	if request.ProviderData == nil {
+		return
+	}
+
+	pd, ok := request.ProviderData.(*providerData)
+	if !ok {
+		response.Diagnostics.AddError("Unexpected data source configure type",
+			fmt.Sprintf("Expected *providerData, got: %T.", request.ProviderData))
+		return
 	}
+
+	f.providerData = pd
 }
 
 func (f *DownloadFileDataSource) Read(ctx context.Context, request datasource.ReadRequest, response *datasource.ReadResponse) {
@@ -113,13 +282,186 @@ func (f *DownloadFileDataSource) Read(ctx context.Context, request datasource.Re
 		return
 	}
 
-	err := downloadFile(data.OutputFile.ValueString(), data.Url.ValueString())
+	client, err := f.client(&data, &response.Diagnostics)
 	if err != nil {
 		response.Diagnostics.AddError("Download file error", err.Error())
+	}
+	if response.Diagnostics.HasError() {
+		return
+	}
+
+	effectiveURL := client.effectiveURL(ctx, data.Url.ValueString())
+	if !isValidURL(effectiveURL) {
+		response.Diagnostics.AddError("Download file error", fmt.Sprintf("Invalid URL after rewrite: %s", effectiveURL))
+		return
+	}
+	data.EffectiveURL = types.StringValue(effectiveURL)
+
+	opts := data.requestOptions(ctx, &response.Diagnostics)
+	if response.Diagnostics.HasError() {
+		return
+	}
+
+	var cache *artifactCache
+	var sandbox *outputSandbox
+	if f.providerData != nil {
+		cache = f.providerData.cache
+		sandbox = f.providerData.sandbox
+	}
+
+	// Links placed from the cache are replaced rather than followed, even when
+	// this download bypasses the cache.
+	linkCache := cache
+
+	if !opts.cacheable() {
+		if client.offline {
+			response.Diagnostics.AddError("Download file error",
+				"Offline mode only serves GET downloads without a request body from the cache.")
+			return
+		}
+		cache = nil
+	}
+
+	includeContent := data.includeContent()
+	if includeContent {
+		opts.MaxSize = defaultMaxContentSize
+		if !data.MaxContentSize.IsNull() && !data.MaxContentSize.IsUnknown() {
+			opts.MaxSize = data.MaxContentSize.ValueInt64()
+		}
+	} else if data.OutputFile.IsNull() {
+		response.Diagnostics.AddAttributeError(path.Root("include_content"), "Download file error",
+			"Either output_file must be set or include_content enabled.")
+		return
+	}
+
+	var outputFile string
+	var fileOpts fileOptions
+	if data.OutputFile.IsNull() {
+		tmp, err := os.CreateTemp("", "terraform-provider-download-*")
+		if err != nil {
+			response.Diagnostics.AddError("Download file error", err.Error())
+			return
+		}
+		_ = tmp.Close()
+		outputFile = tmp.Name()
+		defer func() {
+			_ = os.Remove(outputFile)
+		}()
+	} else {
+		if data.OutputFile.ValueString() == "" {
+			response.Diagnostics.AddAttributeError(path.Root("output_file"), "Download file error",
+				"output_file must not be empty. Omit it to only return the content.")
+			return
+		}
+
+		outputFile, err = sandbox.resolve(data.OutputFile.ValueString())
+		if err != nil {
+			response.Diagnostics.AddAttributeError(path.Root("output_file"), "Download file error", err.Error())
+			return
+		}
+		outputFile = followOutputLink(outputFile, linkCache)
+
+		fileOpts = data.fileOptions(&response.Diagnostics)
+		if response.Diagnostics.HasError() {
+			return
+		}
+
+		if err := fileOpts.prepare(outputFile); err != nil {
+			response.Diagnostics.AddAttributeError(path.Root("output_file"), "Download file error", err.Error())
+			return
+		}
+	}
+
+	verifySHA256 := data.VerifySHA256.ValueString()
+	current := !data.OutputFile.IsNull() && verifySHA256 != "" && fileMatchesSHA256(outputFile, verifySHA256)
+
+	filename := outputFile
+	var staged *stagedFile
+	if !current {
+		staged, err = newStagedFile(outputFile)
+		if err != nil {
+			response.Diagnostics.AddError("Download file error", err.Error())
+			return
+		}
+		defer staged.cleanup()
+		filename = staged.name
+	}
+
+	blob, cached := "", false
+	if verifySHA256 != "" && !current {
+		blob, cached = cache.lookup(effectiveURL, verifySHA256)
+	}
+
+	data.FinalURL = types.StringValue(effectiveURL)
+	data.RedirectChain = types.ListValueMust(types.StringType, []attr.Value{})
+	data.ResponseHeaders = types.MapValueMust(types.StringType, map[string]attr.Value{})
+	data.StatusCode = types.Int64Null()
+	data.ContentType = types.StringNull()
+	data.LastModified = types.StringNull()
+	data.Downloaded = types.BoolValue(false)
+
+	var result *downloadResult
+	switch {
+	case current:
+		tflog.Info(ctx, "Output file matches the pinned checksum, skipping download", map[string]interface{}{
+			"file":   outputFile,
+			"sha256": verifySHA256,
+		})
+	case client.offline:
+		err = restoreFromCache(cache, effectiveURL, verifySHA256, filename)
+	case cached:
+		tflog.Info(ctx, "Using cached artifact", map[string]interface{}{
+			"url":    effectiveURL,
+			"sha256": verifySHA256,
+		})
+		err = cache.place(blob, filename)
+	default:
+		if !data.OutputFile.IsNull() && opts.cacheable() {
+			opts.Validators = loadValidators(outputFile, effectiveURL)
+		}
+		result, err = downloadFile(ctx, client, filename, effectiveURL, opts)
+		if err != nil && !data.OutputFile.IsNull() {
+			staged.keep()
+		}
+		if err == nil {
+			data.Downloaded = types.BoolValue(!result.NotModified)
+			data.FinalURL = types.StringValue(result.FinalURL)
+			var diags diag.Diagnostics
+			data.RedirectChain, diags = types.ListValueFrom(ctx, types.StringType, result.RedirectChain)
+			response.Diagnostics.Append(diags...)
+			data.setResponse(result)
+			if len(result.CredentialSources) > 0 {
+				response.Diagnostics.AddWarning("Download credentials",
+					fmt.Sprintf("The download used credentials from %s.", strings.Join(result.CredentialSources, " and ")))
+			}
+		}
+		if err == nil && result.NotModified {
+			tflog.Info(ctx, "Output file not modified on the server", map[string]interface{}{
+				"file": outputFile,
+				"url":  effectiveURL,
+			})
+			staged.cleanup()
+			staged, filename = nil, outputFile
+		}
+	}
+	var pinErr *pinMismatchError
+	if errors.As(err, &pinErr) {
+		response.Diagnostics.AddError("TLS public key pin mismatch",
+			fmt.Sprintf("The certificates presented by %s match none of the configured spki_sha256_pins. "+
+				"The server presented the public key sha256/%s.", pinErr.Host, pinErr.Presented))
+		return
+	}
+	if err != nil {
+		response.Diagnostics.AddError("Download file error", err.Error())
+		return
+	}
+
+	if err := fileOpts.apply(ctx, filename); err != nil {
+		response.Diagnostics.AddError("Download file error", err.Error())
 		return
 	}
 
-	fi, err := os.Stat(data.OutputFile.ValueString())
+	fi, err := os.Stat(filename)
 	if err != nil {
 		response.Diagnostics.AddError("Download file error", err.Error())
 		return
@@ -127,7 +469,7 @@ func (f *DownloadFileDataSource) Read(ctx context.Context, request datasource.Re
 
 	data.FileSize = types.Int64Value(fi.Size())
 
-	err = genFileShas(data.OutputFile.ValueString(), &data)
+	err = genFileShas(filename, &data)
 	if err != nil {
 		response.Diagnostics.AddError("Download file error", err.Error())
 		return
@@ -139,13 +481,262 @@ func (f *DownloadFileDataSource) Read(ctx context.Context, request datasource.Re
 		return
 	}
 
+	data.Content = types.StringNull()
+	data.ContentBase64 = types.StringNull()
+	data.SensitiveContent = types.StringNull()
+	data.SensitiveContentBase64 = types.StringNull()
+	if includeContent {
+		if opts.MaxSize > 0 && fi.Size() > opts.MaxSize {
+			response.Diagnostics.AddError("Download file error", errContentTooLarge(opts.MaxSize).Error())
+			return
+		}
+
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			response.Diagnostics.AddError("Download file error", err.Error())
+			return
+		}
+		data.setContent(content)
+	}
+
+	if staged != nil {
+		if err := staged.commit(); err != nil {
+			response.Diagnostics.AddError("Download file error", err.Error())
+			return
+		}
+	}
+
+	if data.Downloaded.ValueBool() && !data.OutputFile.IsNull() && opts.cacheable() {
+		if err := saveValidators(outputFile, effectiveURL, result); err != nil {
+			tflog.Warn(ctx, "Could not store the response validators", map[string]interface{}{
+				"file":  outputFile,
+				"error": err.Error(),
+			})
+		}
+	}
+
+	if cache != nil && !client.offline && !current {
+		var header http.Header
+		if result != nil {
+			header = result.Header
+		}
+		err = cache.store(effectiveURL, outputFile, header)
+		if err != nil {
+			response.Diagnostics.AddWarning("Download cache error", fmt.Sprintf("Could not add %s to the cache: %s", effectiveURL, err))
+		}
+	}
+
 	response.Diagnostics.Append(response.State.Set(ctx, &data)...)
 }
 
-func downloadFile(filepath string, url string) error {
-	resp, err := http.Get(url)
+// fileOptions returns the output file settings of the data source.
+func (m *DownloadFileDataSourceModel) fileOptions(diags *diag.Diagnostics) fileOptions {
+	opts := fileOptions{CreateDirectories: m.CreateDirectories.ValueBool()}
+
+	for _, attribute := range []struct {
+		name   string
+		value  types.String
+		target *os.FileMode
+	}{
+		{name: "file_permission", value: m.FilePermission, target: &opts.Mode},
+		{name: "directory_permission", value: m.DirectoryPermission, target: &opts.DirMode},
+	} {
+		if attribute.value.IsNull() || attribute.value.IsUnknown() {
+			continue
+		}
+
+		mode, err := parseFileMode(attribute.value.ValueString())
+		if err != nil {
+			diags.AddAttributeError(path.Root(attribute.name), "Download file error", err.Error())
+		}
+		*attribute.target = mode
+	}
+
+	return opts
+}
+
+// includeContent reports whether the body is returned in the content
+// attributes.
+func (m *DownloadFileDataSourceModel) includeContent() bool {
+	if m.IncludeContent.IsNull() || m.IncludeContent.IsUnknown() {
+		return m.OutputFile.IsNull()
+	}
+
+	return m.IncludeContent.ValueBool()
+}
+
+// setContent fills the content attributes, or their sensitive variants.
+// Bodies that are not valid UTF-8 are only returned base64 encoded.
+func (m *DownloadFileDataSourceModel) setContent(content []byte) {
+	text := types.StringNull()
+	if utf8.Valid(content) {
+		text = types.StringValue(string(content))
+	}
+	encoded := types.StringValue(base64.StdEncoding.EncodeToString(content))
+
+	if m.ContentSensitive.ValueBool() {
+		m.SensitiveContent, m.SensitiveContentBase64 = text, encoded
+	} else {
+		m.Content, m.ContentBase64 = text, encoded
+	}
+}
+
+// setResponse fills the computed attributes describing the response.
+func (m *DownloadFileDataSourceModel) setResponse(result *downloadResult) {
+	headers := map[string]attr.Value{}
+	for name, values := range result.Header {
+		headers[name] = types.StringValue(strings.Join(values, ", "))
+	}
+
+	m.ResponseHeaders = types.MapValueMust(types.StringType, headers)
+	m.StatusCode = types.Int64Value(int64(result.StatusCode))
+
+	if contentType := result.Header.Get("Content-Type"); contentType != "" {
+		m.ContentType = types.StringValue(contentType)
+	}
+
+	if lastModified := result.Header.Get("Last-Modified"); lastModified != "" {
+		m.LastModified = types.StringValue(lastModified)
+	}
+}
+
+// requestOptions returns the request settings of the data source.
+func (m *DownloadFileDataSourceModel) requestOptions(ctx context.Context, diags *diag.Diagnostics) requestOptions {
+	opts := requestOptions{
+		Method:             strings.ToUpper(m.Method.ValueString()),
+		RetryNonIdempotent: m.RetryNonIdempotent.ValueBool(),
+	}
+
+	if !m.RequestHeaders.IsNull() && !m.RequestHeaders.IsUnknown() {
+		diags.Append(m.RequestHeaders.ElementsAs(ctx, &opts.Headers, false)...)
+	}
+
+	if !m.ExpectedStatusCodes.IsNull() && !m.ExpectedStatusCodes.IsUnknown() {
+		diags.Append(m.ExpectedStatusCodes.ElementsAs(ctx, &opts.ExpectedStatusCodes, false)...)
+		for _, code := range opts.ExpectedStatusCodes {
+			if code < 100 || code > 599 {
+				diags.AddAttributeError(path.Root("expected_status_codes"), "Download file error", fmt.Sprintf("Invalid HTTP status code %d", code))
+			}
+		}
+	}
+
+	if opts.Method != "" && !isValidMethod(opts.Method) {
+		diags.AddAttributeError(path.Root("method"), "Download file error", fmt.Sprintf("Invalid HTTP method %q", m.Method.ValueString()))
+	}
+
+	switch {
+	case !m.RequestBody.IsNull() && !m.RequestBodyBase64.IsNull():
+		diags.AddAttributeError(path.Root("request_body_base64"), "Download file error",
+			"Only one of request_body and request_body_base64 can be set.")
+	case !m.RequestBody.IsNull():
+		opts.Body = []byte(m.RequestBody.ValueString())
+	case !m.RequestBodyBase64.IsNull():
+		body, err := base64.StdEncoding.DecodeString(m.RequestBodyBase64.ValueString())
+		if err != nil {
+			diags.AddAttributeError(path.Root("request_body_base64"), "Download file error",
+				fmt.Sprintf("Could not decode request_body_base64: %s", err))
+		}
+		opts.Body = body
+	}
+
+	return opts
+}
+
+// client returns the provider client, or a dedicated one when the data
+// source overrides any client settings. Dedicated clients are kept by the
+// provider and shared by data sources with the same overrides.
+func (f *DownloadFileDataSource) client(data *DownloadFileDataSourceModel, diags *diag.Diagnostics) (*downloadClient, error) {
+	pd := f.providerData
+	if pd == nil {
+		cfg := defaultClientConfig("")
+		pd = &providerData{config: cfg, client: newDownloadClient(cfg)}
+	}
+
+	cfg := pd.config
+	cfg.Redirects = cfg.Redirects.override(data.MaxRedirects, data.AllowInsecureRedirects, data.AllowCrossHostRedirects, path.Empty(), diags)
+
+	if data.TLS == nil && cfg.Redirects == pd.config.Redirects {
+		return pd.client, nil
+	}
+
+	key := derivedClientKey{redirects: cfg.Redirects}
+	if data.TLS != nil {
+		key.tls = fmt.Sprintf("%v", *data.TLS)
+	}
+
+	return pd.derivedClient(key, func() (*downloadClient, error) {
+		if data.TLS != nil {
+			tlsConfig, err := buildTLSConfig(mergeTLSModel(pd.tls, data.TLS))
+			if err != nil {
+				return nil, fmt.Errorf("invalid tls configuration: %s", err)
+			}
+			cfg.TLSConfig = tlsConfig
+		}
+
+		return newDownloadClient(cfg), nil
+	})
+}
+
+// downloadResult describes the response a download was served from.
+type downloadResult struct {
+	FinalURL      string
+	RedirectChain []string
+	StatusCode    int
+	Header        http.Header
+
+	// CredentialSources names the sources of the credentials sent along the
+	// redirect chain.
+	CredentialSources []string
+
+	// NotModified is set when the server answered a conditional request
+	// with 304 and the output file was left untouched. StatusCode and Header
+	// are then those of the response the file was downloaded from.
+	NotModified bool
+}
+
+func downloadFile(ctx context.Context, client *downloadClient, filepath string, url string, opts requestOptions) (*downloadResult, error) {
+	var result *downloadResult
+	err := opts.retryPolicy(ctx, client).withRetry(ctx, url, func() error {
+		var attemptErr error
+		result, attemptErr = downloadFileAttempt(ctx, client, filepath, url, opts)
+		if errors.Is(attemptErr, errResumeRejected) {
+			result, attemptErr = downloadFileAttempt(ctx, client, filepath, url, opts)
+		}
+		return attemptErr
+	})
+
+	return result, err
+}
+
+// downloadFileAttempt resumes a partial download left in filepath by an
+// earlier attempt with a Range request. If-Range makes the server send the
+// full body instead when the file changed in the meantime.
+func downloadFileAttempt(ctx context.Context, client *downloadClient, filepath string, url string, opts requestOptions) (*downloadResult, error) {
+	offset, validator := int64(0), ""
+	if opts.cacheable() {
+		offset, validator = loadResumeRecord(filepath, url)
+	}
+
+	req, err := client.newRequest(ctx, opts.method(), url, opts.body())
 	if err != nil {
-		return err
+		return nil, err
+	}
+	req = opts.apply(req)
+
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", validator)
+	}
+
+	release, err := client.limiter.acquire(ctx, req.URL.Host)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	resp, err := client.do(req)
+	if err != nil {
+		return nil, err
 	}
 
 	defer func(Body io.ReadCloser) {
@@ -156,13 +747,54 @@ func downloadFile(filepath string, url string) error {
 		}
 	}(resp.Body)
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("bad status: %s", resp.Status)
+	result := &downloadResult{
+		FinalURL:      resp.Request.URL.String(),
+		RedirectChain: redirectChain(resp),
+		StatusCode:    resp.StatusCode,
+		Header:        resp.Header,
+
+		CredentialSources: client.credentialSources(resp),
 	}
 
-	out, err := os.Create(filepath)
+	if resp.StatusCode == http.StatusNotModified && opts.Validators != nil {
+		result.NotModified = true
+		opts.Validators.restore(result)
+		return result, nil
+	}
+
+	resumed := offset > 0 && resp.StatusCode == http.StatusPartialContent
+	switch {
+	case offset > 0 && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		if err := removeResumeRecord(filepath); err != nil {
+			return nil, err
+		}
+		return nil, errResumeRejected
+	case resumed:
+		start, err := contentRangeStart(resp.Header)
+		if err != nil || start != offset {
+			if err := removeResumeRecord(filepath); err != nil {
+				return nil, err
+			}
+			return nil, errResumeRejected
+		}
+		tflog.Info(ctx, "Resuming partial download", map[string]interface{}{
+			"url":    url,
+			"offset": offset,
+		})
+		// The resumed file holds the full body of a 200 response.
+		result.StatusCode = http.StatusOK
+	case !opts.accepts(resp.StatusCode):
+		return nil, client.statusError(resp)
+	}
+
+	var out *os.File
+	if resumed {
+		out, err = os.OpenFile(filepath, os.O_WRONLY|os.O_APPEND, 0)
+	} else {
+		out, err = createDownloadFile(filepath, url, resp.Header, opts)
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer func() {
 		err := out.Close()
@@ -171,12 +803,65 @@ func downloadFile(filepath string, url string) error {
 		}
 	}()
 
-	_, err = io.Copy(out, resp.Body)
+	written := int64(0)
+	if resumed {
+		written = offset
+	}
+
+	if opts.MaxSize > 0 && resp.ContentLength >= 0 && written+resp.ContentLength > opts.MaxSize {
+		return nil, errContentTooLarge(opts.MaxSize)
+	}
+
+	body := io.Reader(resp.Body)
+	if opts.MaxSize > 0 {
+		body = io.LimitReader(resp.Body, opts.MaxSize-written+1)
+	}
+
+	n, err := io.Copy(out, body)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	if opts.MaxSize > 0 && written+n > opts.MaxSize {
+		return nil, errContentTooLarge(opts.MaxSize)
+	}
+
+	if err := out.Sync(); err != nil {
+		return nil, err
+	}
+
+	if err := removeResumeRecord(filepath); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// createDownloadFile replaces filepath with an empty file for the body of a
+// full response, recording how to resume it should the transfer fail.
+func createDownloadFile(filepath string, url string, header http.Header, opts requestOptions) (*os.File, error) {
+	if err := removeResumeRecord(filepath); err != nil {
+		return nil, err
+	}
+
+	// The output may be linked to a cache blob, so never write through it.
+	if err := os.Remove(filepath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	out, err := os.Create(filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.cacheable() {
+		if err := saveResumeRecord(filepath, url, header); err != nil {
+			_ = out.Close()
+			return nil, err
+		}
+	}
+
+	return out, nil
 }
 
 func genFileShas(filename string, data *DownloadFileDataSourceModel) error {
@@ -208,6 +893,13 @@ func genFileShas(filename string, data *DownloadFileDataSourceModel) error {
 	return nil
 }
 
+// fileMatchesSHA256 reports whether filename exists and has the sha256
+// checksum sum.
+func fileMatchesSHA256(filename string, sum string) bool {
+	actual, err := fileSHA256(filename)
+	return err == nil && actual == sum
+}
+
 func verifyFileShas(data *DownloadFileDataSourceModel) error {
 	if !data.VerifySHA256.IsNull() {
 		if data.VerifySHA256.ValueString() != data.SHA256.ValueString() {
@@ -230,11 +922,19 @@ func verifyFileShas(data *DownloadFileDataSourceModel) error {
 	return nil
 }
 
+// isValidMethod reports whether method is an HTTP token (RFC 9110,
+// section 5.6.2).
+func isValidMethod(method string) bool {
+	return method != "" && strings.IndexFunc(method, func(r rune) bool {
+		return r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("!#$%&'*+-.^_`|~", r))
+	}) < 0
+}
+
 func isValidURL(u string) bool {
 	parsedURL, err := url.Parse(u)
 	if err != nil || parsedURL.Scheme == "" || parsedURL.Host == "" {
 		return false
 	}
 
-	return strings.HasPrefix(parsedURL.Scheme, "http")
+	return parsedURL.Scheme == "http" || parsedURL.Scheme == "https"
 }
@@ -2,6 +2,7 @@ package provider
 
 import (
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"os"
 	"regexp"
 	"testing"
 )
@@ -31,7 +32,7 @@ data "download_file" "test" {
 }
 
 func TestAccDownloadDataSourceDownloadFile_NoOutputFile(t *testing.T) {
-	expectedError, _ := regexp.Compile(".*open : no such file or directory.*")
+	expectedError, _ := regexp.Compile(".*output_file must not be empty.*")
 	config := `
 data "download_file" "test" {
   url           = "http://localhost:8080/file.dat"
@@ -203,3 +204,86 @@ data "download_file" "test" {
 		},
 	})
 }
+
+func TestAccDownloadDataSourceDownloadFile_URLRewrite(t *testing.T) {
+	config := `
+provider "download" {
+  url_rewrite {
+    prefix      = "https://releases.example.com/"
+    replacement = "http://localhost:8080/"
+  }
+}
+
+data "download_file" "test" {
+  url           = "https://releases.example.com/file.dat"
+  output_file   = "file.dat"
+}
+`
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() {},
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.download_file.test", "url", "https://releases.example.com/file.dat"),
+					resource.TestCheckResourceAttr("data.download_file.test", "effective_url", "http://localhost:8080/file.dat"),
+					resource.TestCheckResourceAttr("data.download_file.test", "output_sha256", "5647f05ec18958947d32874eeb788fa396a05d0bab7c1b71f112ceb7e9b31eee"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDownloadDataSourceDownloadFile_Content(t *testing.T) {
+	config := `
+data "download_file" "test" {
+  url = "http://localhost:8080/file.dat"
+}
+`
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() {},
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckNoResourceAttr("data.download_file.test", "output_file"),
+					resource.TestCheckResourceAttrSet("data.download_file.test", "content_base64"),
+					resource.TestCheckResourceAttr("data.download_file.test", "output_sha256", "5647f05ec18958947d32874eeb788fa396a05d0bab7c1b71f112ceb7e9b31eee"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDownloadDataSourceDownloadFile_SkipMatchingFile(t *testing.T) {
+	config := `
+data "download_file" "test" {
+  url           = "http://localhost:8080/file.dat"
+  output_file   = "skip.dat"
+
+  verify_sha256 = "5647f05ec18958947d32874eeb788fa396a05d0bab7c1b71f112ceb7e9b31eee"
+}
+`
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() {},
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				PreConfig: func() { _ = os.Remove("skip.dat") },
+				Config:    config,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.download_file.test", "downloaded", "true"),
+				),
+			},
+			{
+				Config: config,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.download_file.test", "downloaded", "false"),
+					resource.TestCheckResourceAttr("data.download_file.test", "output_md5", "b2d1236c286a3c0704224fe4105eca49"),
+				),
+			},
+		},
+	})
+}
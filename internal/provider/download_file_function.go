@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"io"
 	"log"
 	"net/http"
@@ -14,10 +16,13 @@ import (
 var _ function.Function = &DownloadFileFunction{}
 
 type DownloadFileFunction struct {
+	providerData func() (*providerData, error)
 }
 
-func NewDownloadFileFunction() function.Function {
-	return &DownloadFileFunction{}
+func NewDownloadFileFunction(providerData func() (*providerData, error)) function.Function {
+	return &DownloadFileFunction{
+		providerData: providerData,
+	}
 }
 
 func (d *DownloadFileFunction) Metadata(ctx context.Context, request function.MetadataRequest, response *function.MetadataResponse) {
@@ -26,8 +31,9 @@ func (d *DownloadFileFunction) Metadata(ctx context.Context, request function.Me
 
 func (d *DownloadFileFunction) Definition(ctx context.Context, request function.DefinitionRequest, response *function.DefinitionResponse) {
 	response.Definition = function.Definition{
-		Summary:     "Downloads a file, returning the filename.",
-		Description: "Downloads a file from a given URL and returns the filename.",
+		Summary:             "Downloads a file, returning the filename.",
+		Description:         "Downloads a file from a given URL and returns the filename.",
+		MarkdownDescription: "Downloads a file from a given URL and returns the filename. An existing file downloaded with an `ETag` or `Last-Modified` validator is revalidated with a conditional request and kept when the server answers `304 Not Modified`. An interrupted download is kept as a hidden `.<name>.part` file and resumed with a `Range` request when the server supports it. The provider block does not apply to this function, since Terraform calls provider functions without configuring the provider. Request settings are passed in `options` instead. The `DOWNLOAD_OFFLINE` and `DOWNLOAD_CACHE_DIR` environment variables enable offline mode and the download cache, and an offline call without a cache fails rather than accessing the network. `DOWNLOAD_BLOCK_PRIVATE_ADDRESSES` refuses loopback, link-local, private and other reserved addresses as the provider `block_private_addresses` does. The `allowed_hosts` and `denied_hosts` lists do not apply.",
 
 		Parameters: []function.Parameter{
 			function.StringParameter{
@@ -36,9 +42,13 @@ func (d *DownloadFileFunction) Definition(ctx context.Context, request function.
 			},
 			function.StringParameter{
 				Name:        "filename",
-				Description: "Name of the filename for the contents.",
+				Description: "Name of the filename for the contents. Relative names are resolved against the `DOWNLOAD_BASE_DIR` environment variable when it is set, and names outside it are rejected, as for the provider `base_dir`.",
 			},
 		},
+		VariadicParameter: function.DynamicParameter{
+			Name:        "options",
+			Description: "Optional object of download options. `request_headers` is a map of HTTP headers sent with the download and metadata requests, only to the host of `url`. `expected_status_codes` lists the HTTP status codes accepted for the download and defaults to `[200]`. `file_permission` and `directory_permission` are octal modes such as `\"0755\"`, and `create_directories` creates missing parent directories. `timeout` limits the whole call, e.g. `\"10m\"`, and defaults to no limit.",
+		},
 		Return: function.StringReturn{},
 	}
 }
@@ -46,9 +56,13 @@ func (d *DownloadFileFunction) Definition(ctx context.Context, request function.
 func (d *DownloadFileFunction) Run(ctx context.Context, request function.RunRequest, response *function.RunResponse) {
 	var url string
 	var filename string
+	var optionValues []types.Dynamic
 	skipDownload := false
 
-	response.Error = function.ConcatFuncErrors(response.Error, request.Arguments.Get(ctx, &url, &filename))
+	response.Error = function.ConcatFuncErrors(response.Error, request.Arguments.Get(ctx, &url, &filename, &optionValues))
+	if response.Error != nil {
+		return
+	}
 
 	if !isValidURL(url) {
 		response.Error = function.NewFuncError("invalid url")
@@ -60,38 +74,193 @@ func (d *DownloadFileFunction) Run(ctx context.Context, request function.RunRequ
 		return
 	}
 
-	info, _ := os.Stat(filename)
-	if info != nil {
-		_, contentLength, err := getRemoteFileMetadata(url)
+	options, err := parseFileFunctionOptions(ctx, optionValues)
+	if err != nil {
+		response.Error = function.NewArgumentFuncError(2, err.Error())
+		return
+	}
+
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+		defer cancel()
+	}
+
+	pd, err := d.providerData()
+	if err != nil {
+		response.Error = function.NewFuncError(fmt.Sprintf("error configuring client: %v", err))
+		return
+	}
+
+	filename, err = pd.sandbox.resolve(filename)
+	if err != nil {
+		response.Error = function.NewArgumentFuncError(1, err.Error())
+		return
+	}
+
+	output := followOutputLink(filename, pd.cache)
+
+	if err := options.File.prepare(output); err != nil {
+		response.Error = function.NewFuncError(err.Error())
+		return
+	}
+
+	url = pd.client.effectiveURL(ctx, url)
+	if !isValidURL(url) {
+		response.Error = function.NewFuncError(fmt.Sprintf("invalid url after rewrite: %s", url))
+		return
+	}
+
+	if pd.client.offline {
+		err := restoreFromCache(pd.cache, url, "", output)
+		if err != nil {
+			response.Error = function.NewFuncError(fmt.Sprintf("error downloading file: %v", err))
+			return
+		}
+
+		d.finish(ctx, output, filename, options, response)
+		return
+	}
+
+	opts := options.Request
+	var remote *remoteMetadata
+	info, _ := os.Stat(output)
+	if info != nil && opts.cacheable() {
+		opts.Validators = loadValidators(output, url)
+	}
+
+	_, cacheErr := pd.cache.entry(url)
+	if opts.Validators == nil && (info != nil || cacheErr == nil) {
+		// The probe only saves a download, so when it fails the remote file
+		// is treated as unknown and downloaded.
+		remote, err = getRemoteFileMetadata(ctx, pd.client, url, opts)
 		if err != nil {
-			response.Error = function.NewFuncError(fmt.Sprintf("error getting remote metadata: %v", err))
+			tflog.Warn(ctx, "Could not get the remote file metadata", map[string]interface{}{
+				"url":   url,
+				"error": err.Error(),
+			})
 		}
 
-		if info.Size() == contentLength {
+		if info != nil && remote != nil && info.Size() == remote.ContentLength {
 			skipDownload = true
 		}
 	}
 
 	if !skipDownload {
-		err := downloadFile(filename, url)
+		err := downloadWithCache(ctx, pd.client, pd.cache, output, url, remote, opts)
 		if err != nil {
 			response.Error = function.NewFuncError(fmt.Sprintf("error downloading file: %v", err))
 			return
 		}
 	}
 
+	d.finish(ctx, output, filename, options, response)
+}
+
+// finish applies the file options to the downloaded output and returns the
+// filename it was requested as.
+func (d *DownloadFileFunction) finish(ctx context.Context, output string, filename string, options fileFunctionOptions, response *function.RunResponse) {
+	if err := options.File.apply(ctx, output); err != nil {
+		response.Error = function.ConcatFuncErrors(response.Error, function.NewFuncError(fmt.Sprintf("error setting file permission: %v", err)))
+		return
+	}
+
 	response.Error = function.ConcatFuncErrors(response.Error, response.Result.Set(ctx, filename))
 }
 
-func getRemoteFileMetadata(url string) (etag string, contentLength int64, err error) {
-	req, err := http.NewRequest("HEAD", url, nil)
+// downloadWithCache restores url from the cache when remote, its metadata on
+// the server, still matches the validators of the cached entry, and otherwise
+// downloads it and records it in the cache. filename is only replaced once
+// the new content is complete, and is left alone when a conditional request
+// reports it unchanged.
+func downloadWithCache(ctx context.Context, client *downloadClient, cache *artifactCache, filename string, url string, remote *remoteMetadata, opts requestOptions) error {
+	staged, err := newStagedFile(filename)
 	if err != nil {
-		return "", 0, err
+		return err
 	}
+	defer staged.cleanup()
 
-	resp, err := http.DefaultClient.Do(req)
+	if entry, err := cache.entry(url); err == nil && entry.current(remote) {
+		if blob, ok := cache.lookup(url, entry.SHA256); ok {
+			tflog.Info(ctx, "Using cached artifact", map[string]interface{}{
+				"url":    url,
+				"sha256": entry.SHA256,
+			})
+			if err := cache.place(blob, staged.name); err != nil {
+				return err
+			}
+			return staged.commit()
+		}
+	}
+
+	result, err := downloadFile(ctx, client, staged.name, url, opts)
 	if err != nil {
-		return "", 0, err
+		staged.keep()
+		return err
+	}
+
+	if result.NotModified {
+		tflog.Info(ctx, "File not modified on the server", map[string]interface{}{
+			"file": filename,
+			"url":  url,
+		})
+		return nil
+	}
+
+	if err := staged.commit(); err != nil {
+		return err
+	}
+
+	if opts.cacheable() {
+		if err := saveValidators(filename, url, result); err != nil {
+			tflog.Warn(ctx, "Could not store the response validators", map[string]interface{}{
+				"file":  filename,
+				"error": err.Error(),
+			})
+		}
+	}
+
+	if cache != nil {
+		err = cache.store(url, filename, result.Header)
+		if err != nil {
+			tflog.Warn(ctx, "Could not add download to the cache", map[string]interface{}{
+				"url":   url,
+				"error": err.Error(),
+			})
+		}
+	}
+
+	return nil
+}
+
+// remoteMetadata is what a HEAD request reports about a remote file.
+type remoteMetadata struct {
+	ETag          string
+	LastModified  string
+	ContentLength int64
+}
+
+func getRemoteFileMetadata(ctx context.Context, client *downloadClient, url string, opts requestOptions) (*remoteMetadata, error) {
+	var metadata *remoteMetadata
+	err := client.retry.withRetry(ctx, url, func() error {
+		var attemptErr error
+		metadata, attemptErr = getRemoteFileMetadataAttempt(ctx, client, url, opts)
+		return attemptErr
+	})
+
+	return metadata, err
+}
+
+func getRemoteFileMetadataAttempt(ctx context.Context, client *downloadClient, url string, opts requestOptions) (*remoteMetadata, error) {
+	req, err := client.newRequest(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = opts.apply(req)
+
+	resp, err := client.do(req)
+	if err != nil {
+		return nil, err
 	}
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
@@ -100,15 +269,22 @@ func getRemoteFileMetadata(url string) (etag string, contentLength int64, err er
 		}
 	}(resp.Body)
 
-	etag = resp.Header.Get("ETag")
+	if client.retry.StatusCodes[resp.StatusCode] {
+		return nil, client.statusError(resp)
+	}
+
+	metadata := &remoteMetadata{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
 
 	contentLengthStr := resp.Header.Get("Content-Length")
 	if contentLengthStr != "" {
-		contentLength, err = strconv.ParseInt(contentLengthStr, 10, 64)
+		metadata.ContentLength, err = strconv.ParseInt(contentLengthStr, 10, 64)
 		if err != nil {
-			return "", 0, err
+			return nil, err
 		}
 	}
 
-	return etag, contentLength, nil
+	return metadata, nil
 }
@@ -100,3 +100,63 @@ output "test" {
 		},
 	})
 }
+
+func TestAccDownloadFileFunction_RequestHeaders(t *testing.T) {
+	_ = os.Remove("file.dat") // remove existing test file
+
+	config := `
+output "test" {
+  value = provider::download::file("http://localhost:8080/file.dat", "file.dat", {
+    request_headers = {
+      Accept = "application/octet-stream"
+    }
+  })
+}
+`
+	resource.Test(t, resource.TestCase{
+		PreCheck: func() {},
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_8_0),
+		},
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownOutputValue("test", knownvalue.StringExact("file.dat")),
+				},
+			},
+		},
+	})
+}
+
+func TestAccDownloadFileFunction_ProviderBlock(t *testing.T) {
+	_ = os.Remove("file.dat") // remove existing test file
+
+	// Terraform calls provider functions without configuring the provider,
+	// so the denied host must not stop the download.
+	config := `
+provider "download" {
+  denied_hosts = ["localhost"]
+}
+
+output "test" {
+  value = provider::download::file("http://localhost:8080/file.dat", "file.dat")
+}
+`
+	resource.Test(t, resource.TestCase{
+		PreCheck: func() {},
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_8_0),
+		},
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownOutputValue("test", knownvalue.StringExact("file.dat")),
+				},
+			},
+		},
+	})
+}
@@ -0,0 +1,93 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+const defaultDirectoryPermission = 0o777
+
+// fileOptions control how output files and their parent directories are
+// created.
+type fileOptions struct {
+	// Mode is the permission of the output file. Zero leaves the mode the
+	// file was created with.
+	Mode              os.FileMode
+	DirMode           os.FileMode
+	CreateDirectories bool
+}
+
+// parseFileMode parses an octal permission such as "0755".
+func parseFileMode(value string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("invalid permission %q, expected an octal mode such as 0644", value)
+	}
+
+	return os.FileMode(mode), nil
+}
+
+// prepare creates the parent directories of filename when enabled.
+func (o fileOptions) prepare(filename string) error {
+	if !o.CreateDirectories {
+		return nil
+	}
+
+	mode := o.DirMode
+	if mode == 0 {
+		mode = defaultDirectoryPermission
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), mode); err != nil {
+		return fmt.Errorf("could not create output directory: %s", err)
+	}
+
+	return nil
+}
+
+// apply sets the configured mode on filename, correcting drift on files that
+// already existed. Symbolic links placed from the cache are left alone and
+// hard links are replaced by a copy first, so the cached blob and other
+// outputs sharing it are never modified.
+func (o fileOptions) apply(ctx context.Context, filename string) error {
+	if o.Mode == 0 {
+		return nil
+	}
+
+	fi, err := os.Lstat(filename)
+	if err != nil {
+		return err
+	}
+
+	if fi.Mode()&os.ModeSymlink != 0 {
+		tflog.Debug(ctx, "Not changing the mode of a symbolic link", map[string]interface{}{
+			"file": filename,
+		})
+		return nil
+	}
+
+	if fi.Mode().Perm() == o.Mode {
+		return nil
+	}
+
+	tflog.Info(ctx, "Correcting file mode", map[string]interface{}{
+		"file":     filename,
+		"mode":     fmt.Sprintf("%#o", fi.Mode().Perm()),
+		"expected": fmt.Sprintf("%#o", o.Mode),
+	})
+
+	if hardLinked(filename, fi) {
+		tflog.Debug(ctx, "Copying hard linked file before changing its mode", map[string]interface{}{
+			"file": filename,
+		})
+		if err := copyFileAtomic(filename, filename); err != nil {
+			return err
+		}
+	}
+
+	return os.Chmod(filename, o.Mode)
+}
@@ -0,0 +1,130 @@
+package provider
+
+import (
+	"context"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseFileMode(t *testing.T) {
+	mode, err := parseFileMode("0640")
+	if err != nil || mode != 0o640 {
+		t.Errorf("expected 0640, got %#o (%v)", mode, err)
+	}
+
+	for _, value := range []string{"", "rwx", "0888", "1777"} {
+		if _, err := parseFileMode(value); err == nil {
+			t.Errorf("%q: expected error", value)
+		}
+	}
+}
+
+func TestFileOptions_Prepare(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "a", "b", "file.dat")
+
+	if err := (fileOptions{}).prepare(filename); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Dir(filename)); !os.IsNotExist(err) {
+		t.Errorf("expected no directory without create_directories, got %v", err)
+	}
+
+	if err := (fileOptions{CreateDirectories: true, DirMode: 0o750}).prepare(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Stat(filepath.Dir(filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm()&^0o750 != 0 {
+		t.Errorf("unexpected directory mode %#o", fi.Mode().Perm())
+	}
+}
+
+func TestFileOptions_Apply(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "file.dat")
+	if err := os.WriteFile(filename, []byte("content"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (fileOptions{Mode: 0o644}).apply(context.Background(), filename); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, _ := os.Stat(filename)
+	if fi.Mode().Perm() != 0o644 {
+		t.Errorf("expected mode drift to be corrected, got %#o", fi.Mode().Perm())
+	}
+
+	link := filepath.Join(dir, "link.dat")
+	if err := os.Symlink(filename, link); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (fileOptions{Mode: 0o600}).apply(context.Background(), link); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, _ = os.Stat(filename)
+	if fi.Mode().Perm() != 0o644 {
+		t.Errorf("expected the link target to be left alone, got %#o", fi.Mode().Perm())
+	}
+}
+
+func TestParseFileFunctionOptions_File(t *testing.T) {
+	options, err := parseFileFunctionOptions(context.Background(), []types.Dynamic{
+		testFunctionOptions(map[string]attr.Value{
+			"file_permission":      types.StringValue("0755"),
+			"directory_permission": types.StringValue("0700"),
+			"create_directories":   types.BoolValue(true),
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if options.File != (fileOptions{Mode: 0o755, DirMode: 0o700, CreateDirectories: true}) {
+		t.Errorf("unexpected file options %+v", options.File)
+	}
+
+	_, err = parseFileFunctionOptions(context.Background(), []types.Dynamic{
+		testFunctionOptions(map[string]attr.Value{"file_permission": types.NumberValue(nil)}),
+	})
+	if err == nil {
+		t.Error("expected error for a numeric file_permission")
+	}
+}
+
+func TestFileOptions_ApplyHardLink(t *testing.T) {
+	dir := t.TempDir()
+	blob := filepath.Join(dir, "blob")
+	if err := os.WriteFile(blob, []byte("content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cache := &artifactCache{dir: dir, linkMode: cacheLinkModeHardlink}
+	filename := filepath.Join(dir, "file.dat")
+	if err := cache.place(blob, filename); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (fileOptions{Mode: 0o700}).apply(context.Background(), filename); err != nil {
+		t.Fatal(err)
+	}
+
+	blobInfo, _ := os.Stat(blob)
+	if blobInfo.Mode().Perm() != 0o644 {
+		t.Errorf("expected the cached blob to keep its mode, got %#o", blobInfo.Mode().Perm())
+	}
+
+	fi, _ := os.Stat(filename)
+	content, _ := os.ReadFile(filename)
+	if fi.Mode().Perm() != 0o700 || string(content) != "content" || os.SameFile(fi, blobInfo) {
+		t.Errorf("expected an unlinked copy with mode 0700, got %#o and %q", fi.Mode().Perm(), content)
+	}
+}
@@ -0,0 +1,168 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// fileFunctionOptions is the optional object passed as the last argument of
+// the file function, e.g. `file(url, filename, { request_headers = {...} })`.
+type fileFunctionOptions struct {
+	Request requestOptions
+	File    fileOptions
+
+	// Timeout limits the whole call, as the provider timeout does for the
+	// data source.
+	Timeout time.Duration
+}
+
+var fileFunctionOptionNames = []string{"create_directories", "directory_permission", "expected_status_codes", "file_permission", "request_headers", "timeout"}
+
+func parseFileFunctionOptions(ctx context.Context, values []types.Dynamic) (fileFunctionOptions, error) {
+	var options fileFunctionOptions
+
+	if len(values) > 1 {
+		return options, fmt.Errorf("expected at most one options object, got %d", len(values))
+	}
+
+	if len(values) == 0 || values[0].IsNull() || values[0].IsUnderlyingValueNull() {
+		return options, nil
+	}
+
+	attributes, ok := attrElements(values[0].UnderlyingValue())
+	if !ok {
+		return options, fmt.Errorf("options must be an object, got %s", values[0].UnderlyingValue().Type(ctx))
+	}
+
+	for name, value := range attributes {
+		switch name {
+		case "request_headers":
+			headers, err := stringMap(value)
+			if err != nil {
+				return options, fmt.Errorf("request_headers: %s", err)
+			}
+			options.Request.Headers = headers
+		case "expected_status_codes":
+			codes, err := intList(value)
+			if err != nil {
+				return options, fmt.Errorf("expected_status_codes: %s", err)
+			}
+			options.Request.ExpectedStatusCodes = codes
+		case "file_permission", "directory_permission":
+			s, ok := value.(types.String)
+			if !ok || s.IsUnknown() {
+				return options, fmt.Errorf("%s must be a string such as \"0644\"", name)
+			}
+			if s.IsNull() {
+				continue
+			}
+			mode, err := parseFileMode(s.ValueString())
+			if err != nil {
+				return options, fmt.Errorf("%s: %s", name, err)
+			}
+			if name == "file_permission" {
+				options.File.Mode = mode
+			} else {
+				options.File.DirMode = mode
+			}
+		case "create_directories":
+			b, ok := value.(types.Bool)
+			if !ok || b.IsUnknown() {
+				return options, fmt.Errorf("create_directories must be a bool")
+			}
+			options.File.CreateDirectories = b.ValueBool()
+		case "timeout":
+			s, ok := value.(types.String)
+			if !ok || s.IsUnknown() {
+				return options, fmt.Errorf("timeout must be a duration string such as \"10m\"")
+			}
+			if s.IsNull() {
+				continue
+			}
+			timeout, err := time.ParseDuration(s.ValueString())
+			if err != nil || timeout <= 0 {
+				return options, fmt.Errorf("timeout: invalid duration %q", s.ValueString())
+			}
+			options.Timeout = timeout
+		default:
+			return options, fmt.Errorf("unknown option %q, expected one of %s", name, strings.Join(fileFunctionOptionNames, ", "))
+		}
+	}
+
+	return options, nil
+}
+
+// attrElements returns the attributes of an object or the elements of a map.
+func attrElements(value attr.Value) (map[string]attr.Value, bool) {
+	switch v := value.(type) {
+	case types.Object:
+		return v.Attributes(), true
+	case types.Map:
+		return v.Elements(), true
+	case types.Dynamic:
+		return attrElements(v.UnderlyingValue())
+	}
+
+	return nil, false
+}
+
+func stringMap(value attr.Value) (map[string]string, error) {
+	if value.IsNull() {
+		return nil, nil
+	}
+
+	elements, ok := attrElements(value)
+	if !ok {
+		return nil, fmt.Errorf("expected a map of strings")
+	}
+
+	values := make(map[string]string, len(elements))
+	for key, element := range elements {
+		s, ok := element.(types.String)
+		if !ok || s.IsUnknown() {
+			return nil, fmt.Errorf("value of %q must be a string", key)
+		}
+		values[key] = s.ValueString()
+	}
+
+	return values, nil
+}
+
+func intList(value attr.Value) ([]int, error) {
+	if value.IsNull() {
+		return nil, nil
+	}
+
+	var elements []attr.Value
+	switch v := value.(type) {
+	case types.List:
+		elements = v.Elements()
+	case types.Tuple:
+		elements = v.Elements()
+	case types.Set:
+		elements = v.Elements()
+	default:
+		return nil, fmt.Errorf("expected a list of numbers")
+	}
+
+	values := make([]int, 0, len(elements))
+	for i, element := range elements {
+		n, ok := element.(types.Number)
+		if !ok || n.IsNull() || n.IsUnknown() {
+			return nil, fmt.Errorf("element %d must be a number", i)
+		}
+
+		i64, accuracy := n.ValueBigFloat().Int64()
+		if accuracy != big.Exact {
+			return nil, fmt.Errorf("element %d must be a whole number", i)
+		}
+		values = append(values, int(i64))
+	}
+
+	return values, nil
+}
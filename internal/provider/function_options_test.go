@@ -0,0 +1,189 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFunctionOptions(attributes map[string]attr.Value) types.Dynamic {
+	attributeTypes := map[string]attr.Type{}
+	for name, value := range attributes {
+		attributeTypes[name] = value.Type(context.Background())
+	}
+
+	return types.DynamicValue(types.ObjectValueMust(attributeTypes, attributes))
+}
+
+func testHeaders(headers map[string]string) attr.Value {
+	attributes := map[string]attr.Value{}
+	for name, value := range headers {
+		attributes[name] = types.StringValue(value)
+	}
+
+	return testFunctionOptions(attributes).UnderlyingValue()
+}
+
+// testFileFunction returns the file function of an unconfigured provider,
+// which is how Terraform calls it.
+func testFileFunction() function.Function {
+	return New("dev")().(*DownloadProvider).Functions(context.Background())[0]()
+}
+
+// runFileFunction calls fn with arguments and returns its error.
+func runFileFunction(fn function.Function, arguments ...attr.Value) *function.FuncError {
+	request := function.RunRequest{Arguments: function.NewArgumentsData(arguments)}
+	response := &function.RunResponse{Result: function.NewResultData(types.StringUnknown())}
+
+	fn.Run(context.Background(), request, response)
+
+	return response.Error
+}
+
+func TestParseFileFunctionOptions(t *testing.T) {
+	options, err := parseFileFunctionOptions(context.Background(), []types.Dynamic{
+		testFunctionOptions(map[string]attr.Value{
+			"request_headers": testHeaders(map[string]string{"Accept": "application/octet-stream"}),
+			"expected_status_codes": types.TupleValueMust([]attr.Type{types.NumberType, types.NumberType}, []attr.Value{
+				types.NumberValue(big.NewFloat(200)),
+				types.NumberValue(big.NewFloat(206)),
+			}),
+			"timeout": types.StringValue("90s"),
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fmt.Sprint(options.Request.ExpectedStatusCodes) != "[200 206]" {
+		t.Errorf("unexpected status codes %v", options.Request.ExpectedStatusCodes)
+	}
+
+	if options.Request.Headers["Accept"] != "application/octet-stream" {
+		t.Errorf("unexpected headers %v", options.Request.Headers)
+	}
+
+	if options.Timeout != 90*time.Second {
+		t.Errorf("unexpected timeout %s", options.Timeout)
+	}
+
+	cases := map[string][]types.Dynamic{
+		"unknown option": {testFunctionOptions(map[string]attr.Value{"headers": types.StringValue("x")})},
+		"not an object":  {types.DynamicValue(types.StringValue("x"))},
+		"not strings": {testFunctionOptions(map[string]attr.Value{
+			"request_headers": testFunctionOptions(map[string]attr.Value{"X-Count": types.NumberNull()}).UnderlyingValue(),
+		})},
+		"fractional status": {testFunctionOptions(map[string]attr.Value{
+			"expected_status_codes": types.TupleValueMust([]attr.Type{types.NumberType}, []attr.Value{types.NumberValue(big.NewFloat(200.5))}),
+		})},
+		"several objects": {testFunctionOptions(nil), testFunctionOptions(nil)},
+		"bad timeout":     {testFunctionOptions(map[string]attr.Value{"timeout": types.StringValue("10")})},
+	}
+
+	for name, values := range cases {
+		if _, err := parseFileFunctionOptions(context.Background(), values); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestDownloadFileFunction_RequestHeaders(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "application/octet-stream" || r.Header.Get("X-Api-Version") != "2022-11-28" {
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+		methods = append(methods, r.Method)
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	fn := testFileFunction()
+	filename := filepath.Join(t.TempDir(), "file.dat")
+	options := testFunctionOptions(map[string]attr.Value{
+		"request_headers": testHeaders(map[string]string{
+			"Accept":        "application/octet-stream",
+			"X-Api-Version": "2022-11-28",
+		}),
+	})
+
+	for i := 0; i < 2; i++ {
+		err := runFileFunction(fn,
+			types.StringValue(server.URL),
+			types.StringValue(filename),
+			types.TupleValueMust([]attr.Type{types.DynamicType}, []attr.Value{options}),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	content, _ := os.ReadFile(filename)
+	if string(content) != "content" {
+		t.Errorf("unexpected content %q", content)
+	}
+
+	if strings.Join(methods, ",") != "GET,HEAD" {
+		t.Errorf("expected the headers on the download and the metadata request, got %v", methods)
+	}
+}
+
+func TestDownloadFileFunction_Timeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	err := runFileFunction(testFileFunction(),
+		types.StringValue(server.URL),
+		types.StringValue(filepath.Join(t.TempDir(), "file.dat")),
+		types.TupleValueMust([]attr.Type{types.DynamicType}, []attr.Value{
+			testFunctionOptions(map[string]attr.Value{"timeout": types.StringValue("50ms")}),
+		}),
+	)
+	if err == nil || !strings.Contains(err.Error(), "deadline exceeded") {
+		t.Errorf("expected the timeout to end the download, got %v", err)
+	}
+}
+
+func TestDownloadFileFunction_MetadataProbeFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	filename := filepath.Join(t.TempDir(), "file.dat")
+	if err := os.WriteFile(filename, []byte("stale"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runFileFunction(testFileFunction(),
+		types.StringValue(server.URL),
+		types.StringValue(filename),
+		types.TupleValueMust([]attr.Type{}, []attr.Value{}),
+	); err != nil {
+		t.Fatalf("expected a failed metadata request to fall back to the download, got %v", err)
+	}
+
+	content, _ := os.ReadFile(filename)
+	if string(content) != "content" {
+		t.Errorf("unexpected content %q", content)
+	}
+}
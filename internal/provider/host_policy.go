@@ -0,0 +1,205 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+)
+
+// blockedPrefixes are the reserved ranges rejected when private addresses
+// are blocked on top of the loopback, private, link-local (including the
+// cloud metadata endpoint 169.254.169.254), multicast and unspecified
+// addresses that netip classifies itself.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),      // this network
+	netip.MustParsePrefix("100.64.0.0/10"),  // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),   // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),  // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),    // reserved, and the broadcast address
+	netip.MustParsePrefix("64:ff9b:1::/48"), // local-use NAT64
+	netip.MustParsePrefix("100::/64"),       // discard-only
+}
+
+var (
+	nat64Prefix     = netip.MustParsePrefix("64:ff9b::/96")
+	sixToFourPrefix = netip.MustParsePrefix("2002::/16")
+)
+
+type hostPolicy struct {
+	AllowedHosts          []string
+	DeniedHosts           []string
+	BlockPrivateAddresses bool
+}
+
+func (p hostPolicy) enabled() bool {
+	return len(p.AllowedHosts) > 0 || len(p.DeniedHosts) > 0
+}
+
+// checkHost matches host against the denied and allowed glob patterns. A
+// denied match always wins.
+func (p hostPolicy) checkHost(host string) error {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	for _, pattern := range p.DeniedHosts {
+		if matchHost(pattern, host) {
+			return fmt.Errorf("host %q is denied by the provider host policy", host)
+		}
+	}
+
+	if len(p.AllowedHosts) == 0 {
+		return nil
+	}
+
+	for _, pattern := range p.AllowedHosts {
+		if matchHost(pattern, host) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("host %q is not in the provider allowed hosts", host)
+}
+
+func matchHost(pattern string, host string) bool {
+	matched, err := path.Match(strings.ToLower(pattern), host)
+	return err == nil && matched
+}
+
+func checkAddress(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("connection to %s blocked: not an IP address", address)
+	}
+
+	if blockedAddress(ip) {
+		return fmt.Errorf("connection to %s blocked: private or reserved address", ip.Unmap())
+	}
+
+	return nil
+}
+
+// blockedAddress reports whether ip is private or reserved. NAT64 and 6to4
+// addresses are judged by the IPv4 address they embed, so they cannot be
+// used to reach a blocked IPv4 address.
+func blockedAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return true
+	}
+
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+
+	b := ip.As16()
+	switch {
+	case nat64Prefix.Contains(ip):
+		return blockedAddress(netip.AddrFrom4([4]byte(b[12:16])))
+	case sixToFourPrefix.Contains(ip):
+		return blockedAddress(netip.AddrFrom4([4]byte(b[2:6])))
+	}
+
+	return false
+}
+
+// guardDialContext rejects connections to private addresses after DNS
+// resolution, so neither DNS rebinding nor redirects can reach them. Dials to
+// the configured proxies are exempt.
+func guardDialContext(dialer *net.Dialer, exempt map[string]bool, dial dialFunc) func(ctx context.Context, network, address string) (net.Conn, error) {
+	guarded := *dialer
+	guarded.Control = func(network, address string, c syscall.RawConn) error {
+		return checkAddress(address)
+	}
+
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		if exempt[address] {
+			return dial(ctx, dialer, network, address)
+		}
+
+		return dial(ctx, &guarded, network, address)
+	}
+}
+
+// policyTransport enforces the host allow and deny lists on every request,
+// including each redirect hop. When private addresses are blocked it also
+// checks the target of proxied requests, since the only dial the guard sees
+// for those is the one to the proxy.
+type policyTransport struct {
+	base     http.RoundTripper
+	policy   hostPolicy
+	proxy    func(*http.Request) (*url.URL, error)
+	resolver resolverConfig
+	lookup   *net.Resolver
+}
+
+func (t *policyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	err := t.policy.checkHost(req.URL.Hostname())
+	if err == nil && t.policy.BlockPrivateAddresses {
+		err = t.checkTarget(req)
+	}
+	if err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, err
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+// checkTarget rejects requests for literal private addresses, and proxied
+// requests for host names resolving to one.
+func (t *policyTransport) checkTarget(req *http.Request) error {
+	host := req.URL.Hostname()
+	if _, err := netip.ParseAddr(host); err == nil {
+		return checkAddress(host)
+	}
+
+	proxyURL, err := t.proxy(req)
+	if err != nil || proxyURL == nil {
+		// Direct connections are checked by the dial guard.
+		return err
+	}
+
+	port := req.URL.Port()
+	if port == "" {
+		port = "80"
+		if req.URL.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	if ip, ok := t.resolver.override(host, port); ok {
+		return checkAddress(ip)
+	}
+
+	lookup := t.lookup
+	if lookup == nil {
+		lookup = net.DefaultResolver
+	}
+
+	addrs, err := lookup.LookupNetIP(req.Context(), "ip", host)
+	if err != nil {
+		return fmt.Errorf("connection to %s blocked: could not resolve it to check its address: %s", host, err)
+	}
+
+	for _, addr := range addrs {
+		if err := checkAddress(addr.String()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
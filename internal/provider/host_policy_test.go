@@ -0,0 +1,178 @@
+package provider
+
+import (
+	"context"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHostPolicy_CheckHost(t *testing.T) {
+	policy := hostPolicy{
+		AllowedHosts: []string{"*.example.com", "releases.hashicorp.com"},
+		DeniedHosts:  []string{"internal.example.com"},
+	}
+
+	cases := map[string]bool{
+		"artifacts.example.com":  true,
+		"Artifacts.Example.com.": true,
+		"releases.hashicorp.com": true,
+		"internal.example.com":   false,
+		"example.com":            false,
+		"169.254.169.254":        false,
+	}
+
+	for host, allowed := range cases {
+		if err := policy.checkHost(host); (err == nil) != allowed {
+			t.Errorf("%s: expected allowed=%t, got %v", host, allowed, err)
+		}
+	}
+}
+
+func TestCheckAddress(t *testing.T) {
+	cases := map[string]bool{
+		"127.0.0.1:80":             true,
+		"169.254.169.254:80":       true,
+		"10.1.2.3:443":             true,
+		"172.20.0.1:443":           true,
+		"192.168.0.10:443":         true,
+		"[::1]:443":                true,
+		"[::ffff:127.0.0.1]:80":    true,
+		"[fd00:ec2::254]:80":       true,
+		"[fe80::1%eth0]:80":        true,
+		"0.0.0.0:80":               true,
+		"100.64.0.1:80":            true,
+		"192.0.0.170:80":           true,
+		"198.18.0.1:80":            true,
+		"240.0.0.1:80":             true,
+		"255.255.255.255:80":       true,
+		"224.0.0.1:80":             true,
+		"[ff02::1]:80":             true,
+		"[::]:80":                  true,
+		"[64:ff9b::a9fe:a9fe]:80":  true,
+		"[64:ff9b::7f00:1]:80":     true,
+		"[2002:a9fe:a9fe::]:80":    true,
+		"[2002:c0a8:1::1]:80":      true,
+		"93.184.215.14:443":        false,
+		"[2606:4700::1111]:443":    false,
+		"[64:ff9b::5db8:d70e]:443": false,
+		"[2002:5db8:d70e::1]:443":  false,
+	}
+
+	for address, blocked := range cases {
+		if err := checkAddress(address); (err != nil) != blocked {
+			t.Errorf("%s: expected blocked=%t, got %v", address, blocked, err)
+		}
+	}
+}
+
+func TestHostPolicy_BlocksResolvedPrivateAddress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client := testClient(func(cfg *clientConfig) {
+		cfg.HostPolicy.BlockPrivateAddresses = true
+	})
+
+	// localhost only turns into a loopback address once it is resolved.
+	target := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+	_, err := downloadFile(context.Background(), client, filepath.Join(t.TempDir(), "file.dat"), target, requestOptions{})
+	if err == nil || !strings.Contains(err.Error(), "private or reserved address") {
+		t.Fatalf("expected blocked connection, got %v", err)
+	}
+}
+
+func TestDownloadFileFunction_BlockPrivateAddressesFromEnvironment(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	t.Setenv("DOWNLOAD_BLOCK_PRIVATE_ADDRESSES", "true")
+
+	err := runFileFunction(testFileFunction(), types.StringValue(server.URL), types.StringValue(filepath.Join(t.TempDir(), "file.dat")), types.TupleValueMust([]attr.Type{}, []attr.Value{}))
+	if err == nil || !strings.Contains(err.Error(), "private or reserved address") {
+		t.Errorf("expected blocked connection, got %v", err)
+	}
+
+	t.Setenv("DOWNLOAD_BLOCK_PRIVATE_ADDRESSES", "maybe")
+	err = runFileFunction(testFileFunction(), types.StringValue(server.URL), types.StringValue(filepath.Join(t.TempDir(), "file.dat")), types.TupleValueMust([]attr.Type{}, []attr.Value{}))
+	if err == nil || !strings.Contains(err.Error(), "invalid DOWNLOAD_BLOCK_PRIVATE_ADDRESSES") {
+		t.Errorf("expected an invalid value to fail, got %v", err)
+	}
+}
+
+func TestHostPolicy_DeniedRedirectTarget(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, strings.Replace(target.URL, "127.0.0.1", "localhost", 1), http.StatusFound)
+	}))
+	defer origin.Close()
+
+	client := testClient(func(cfg *clientConfig) {
+		cfg.HostPolicy.DeniedHosts = []string{"localhost"}
+	})
+
+	_, err := downloadFile(context.Background(), client, filepath.Join(t.TempDir(), "file.dat"), origin.URL, requestOptions{})
+	if err == nil || !strings.Contains(err.Error(), `host "localhost" is denied`) {
+		t.Fatalf("expected denied redirect, got %v", err)
+	}
+}
+
+func TestHostPolicy_ProxyExempt(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer proxy.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	client := testClient(func(cfg *clientConfig) {
+		cfg.ProxyURL = proxyURL
+		cfg.HostPolicy.BlockPrivateAddresses = true
+		cfg.Resolver.Overrides = map[string]string{"artifacts.example.com:80": "93.184.215.14"}
+	})
+
+	_, err := downloadFile(context.Background(), client, filepath.Join(t.TempDir(), "file.dat"), "http://artifacts.example.com/file.dat", requestOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHostPolicy_BlocksProxiedPrivateTarget(t *testing.T) {
+	var requests []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.String())
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer proxy.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	client := testClient(func(cfg *clientConfig) {
+		cfg.ProxyURL = proxyURL
+		cfg.HostPolicy.BlockPrivateAddresses = true
+		cfg.Resolver.Overrides = map[string]string{"metadata.example.com:80": "169.254.169.254"}
+	})
+
+	for _, target := range []string{
+		"http://169.254.169.254/latest/meta-data/",
+		"http://[::ffff:169.254.169.254]/latest/meta-data/",
+		"http://metadata.example.com/latest/meta-data/",
+	} {
+		_, err := downloadFile(context.Background(), client, filepath.Join(t.TempDir(), "file.dat"), target, requestOptions{})
+		if err == nil || !strings.Contains(err.Error(), "private or reserved address") {
+			t.Errorf("%s: expected blocked request, got %v", target, err)
+		}
+	}
+
+	if len(requests) != 0 {
+		t.Errorf("expected the proxy to receive no requests, got %v", requests)
+	}
+}
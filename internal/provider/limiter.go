@@ -0,0 +1,95 @@
+package provider
+
+import (
+	"context"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// transferLimiter bounds the number of simultaneous transfers, globally and
+// per host. Terraform reads data sources in parallel, so without it every
+// download_file would hit the server at once.
+type transferLimiter struct {
+	global  chan struct{}
+	perHost int
+
+	mu    sync.Mutex
+	hosts map[string]chan struct{}
+}
+
+func newTransferLimiter(global int, perHost int) *transferLimiter {
+	if global <= 0 && perHost <= 0 {
+		return nil
+	}
+
+	l := &transferLimiter{
+		perHost: perHost,
+		hosts:   map[string]chan struct{}{},
+	}
+
+	if global > 0 {
+		l.global = make(chan struct{}, global)
+	}
+
+	return l
+}
+
+func (l *transferLimiter) hostSemaphore(host string) chan struct{} {
+	if l.perHost <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	host = strings.ToLower(host)
+	sem, ok := l.hosts[host]
+	if !ok {
+		sem = make(chan struct{}, l.perHost)
+		l.hosts[host] = sem
+	}
+
+	return sem
+}
+
+// acquire blocks until a transfer slot for host is free. The host slot is
+// taken first so a request waiting on a busy host does not hold a global
+// slot. The returned function releases the slots.
+func (l *transferLimiter) acquire(ctx context.Context, host string) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	start := time.Now()
+	var held []chan struct{}
+	release := func() {
+		for _, sem := range held {
+			<-sem
+		}
+	}
+
+	for _, sem := range []chan struct{}{l.hostSemaphore(host), l.global} {
+		if sem == nil {
+			continue
+		}
+
+		select {
+		case sem <- struct{}{}:
+			held = append(held, sem)
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+
+	if wait := time.Since(start); wait > time.Millisecond {
+		tflog.Info(ctx, "Waited for download slot", map[string]interface{}{
+			"host": host,
+			"wait": wait.String(),
+		})
+	}
+
+	return release, nil
+}
@@ -0,0 +1,98 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type concurrencyRecorder struct {
+	mu      sync.Mutex
+	current map[string]int
+	peak    map[string]int
+}
+
+func (r *concurrencyRecorder) handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.mu.Lock()
+		r.current[req.Host]++
+		r.current["*"]++
+		r.peak[req.Host] = max(r.peak[req.Host], r.current[req.Host])
+		r.peak["*"] = max(r.peak["*"], r.current["*"])
+		r.mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+		_, _ = w.Write([]byte("content"))
+
+		r.mu.Lock()
+		r.current[req.Host]--
+		r.current["*"]--
+		r.mu.Unlock()
+	})
+}
+
+func TestTransferLimiter(t *testing.T) {
+	recorder := &concurrencyRecorder{current: map[string]int{}, peak: map[string]int{}}
+	server := httptest.NewServer(recorder.handler())
+	defer server.Close()
+
+	hosts := []string{server.URL, strings.Replace(server.URL, "127.0.0.1", "localhost", 1)}
+
+	client := testClient(func(cfg *clientConfig) {
+		cfg.Limiter = newTransferLimiter(3, 2)
+	})
+
+	dir := t.TempDir()
+	var wg sync.WaitGroup
+	var failures atomic.Int32
+	for i := 0; i < 12; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			output := filepath.Join(dir, fmt.Sprintf("file-%d.dat", i))
+			if _, err := downloadFile(context.Background(), client, output, hosts[i%2], requestOptions{}); err != nil {
+				failures.Add(1)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if failures.Load() != 0 {
+		t.Fatalf("%d downloads failed", failures.Load())
+	}
+
+	if recorder.peak["*"] > 3 {
+		t.Errorf("expected at most 3 concurrent downloads, got %d", recorder.peak["*"])
+	}
+
+	for _, host := range hosts {
+		host = strings.TrimPrefix(host, "http://")
+		if recorder.peak[host] > 2 {
+			t.Errorf("expected at most 2 concurrent downloads from %s, got %d", host, recorder.peak[host])
+		}
+	}
+}
+
+func TestTransferLimiter_Cancel(t *testing.T) {
+	limiter := newTransferLimiter(1, 0)
+
+	release, err := limiter.acquire(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := limiter.acquire(ctx, "example.com"); err == nil {
+		t.Error("expected acquire to fail when the context is done")
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package provider
+
+import (
+	"os"
+	"syscall"
+)
+
+// hardLinked reports whether the file described by fi has other hard links,
+// such as a blob in the provider cache.
+func hardLinked(_ string, fi os.FileInfo) bool {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	return ok && st.Nlink > 1
+}
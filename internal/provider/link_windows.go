@@ -0,0 +1,27 @@
+//go:build windows
+
+package provider
+
+import (
+	"golang.org/x/sys/windows"
+	"os"
+)
+
+// hardLinked reports whether filename has other hard links, such as a blob
+// in the provider cache.
+func hardLinked(filename string, _ os.FileInfo) bool {
+	f, err := os.Open(filename)
+	if err != nil {
+		return false
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var info windows.ByHandleFileInformation
+	if err := windows.GetFileInformationByHandle(windows.Handle(f.Fd()), &info); err != nil {
+		return false
+	}
+
+	return info.NumberOfLinks > 1
+}
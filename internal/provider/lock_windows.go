@@ -0,0 +1,14 @@
+//go:build windows
+
+package provider
+
+import (
+	"golang.org/x/sys/windows"
+	"os"
+)
+
+// tryLockFile takes an exclusive lock on f without waiting. The lock is
+// released when f is closed or the process exits.
+func tryLockFile(f *os.File) error {
+	return windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, &windows.Overlapped{})
+}
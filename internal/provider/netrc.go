@@ -0,0 +1,122 @@
+package provider
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultNetrcPath returns $NETRC or the platform default location of the
+// user's netrc file.
+func defaultNetrcPath() string {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	name := ".netrc"
+	if filepath.Separator == '\\' {
+		name = "_netrc"
+	}
+
+	return filepath.Join(home, name)
+}
+
+// loadNetrc reads the machine entries of a netrc file as basic credentials.
+// A missing file is only an error when the path was set explicitly.
+func loadNetrc(path string, explicit bool) ([]credential, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !explicit {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not read netrc file: %s", err)
+	}
+
+	return parseNetrc(string(content), path)
+}
+
+func parseNetrc(content string, path string) ([]credential, error) {
+	var credentials []credential
+	current := -1
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	inMacro := false
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// A macro definition runs until the next empty line.
+		if inMacro {
+			if strings.TrimSpace(line) == "" {
+				inMacro = false
+			}
+			continue
+		}
+
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields); i++ {
+			token := fields[i]
+
+			value := func() (string, error) {
+				if i+1 >= len(fields) {
+					return "", fmt.Errorf("netrc file %s: missing value for %q", path, token)
+				}
+				i++
+				return fields[i], nil
+			}
+
+			switch token {
+			case "machine":
+				host, err := value()
+				if err != nil {
+					return nil, err
+				}
+				credentials = append(credentials, credential{
+					Host:   strings.ToLower(host),
+					Type:   credentialTypeBasic,
+					Source: fmt.Sprintf("netrc file %s (machine %s)", path, host),
+				})
+				current = len(credentials) - 1
+			case "default":
+				// Like the go command, the default entry is ignored: it
+				// would send its credentials to every other host, including
+				// redirect targets.
+				current = -1
+			case "login", "password", "account":
+				v, err := value()
+				if err != nil {
+					return nil, err
+				}
+				if current < 0 {
+					continue
+				}
+				switch token {
+				case "login":
+					credentials[current].Username = v
+				case "password":
+					credentials[current].Password = v
+				}
+			case "macdef":
+				inMacro = true
+				i = len(fields)
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("netrc file %s: %s", path, err)
+	}
+
+	return credentials, nil
+}
@@ -2,11 +2,20 @@ package provider
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/provider"
 	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"os"
+	"strconv"
+	"sync"
+	"time"
 )
 
 var _ provider.Provider = &DownloadProvider{}
@@ -14,6 +23,92 @@ var _ provider.ProviderWithFunctions = &DownloadProvider{}
 
 type DownloadProvider struct {
 	version string
+
+	// mu guards fnData, the provider data of the file function, which is
+	// built on first use.
+	mu     sync.Mutex
+	fnData *providerData
+}
+
+type DownloadProviderModel struct {
+	Timeout                       types.String      `tfsdk:"timeout"`
+	ConnectTimeout                types.String      `tfsdk:"connect_timeout"`
+	TLSHandshakeTimeout           types.String      `tfsdk:"tls_handshake_timeout"`
+	ResponseHeaderTimeout         types.String      `tfsdk:"response_header_timeout"`
+	IdleConnTimeout               types.String      `tfsdk:"idle_conn_timeout"`
+	UserAgent                     types.String      `tfsdk:"user_agent"`
+	MaxIdleConns                  types.Int64       `tfsdk:"max_idle_conns"`
+	MaxIdleConnsPerHost           types.Int64       `tfsdk:"max_idle_conns_per_host"`
+	MaxConnsPerHost               types.Int64       `tfsdk:"max_conns_per_host"`
+	MaxConcurrentDownloads        types.Int64       `tfsdk:"max_concurrent_downloads"`
+	MaxConcurrentDownloadsPerHost types.Int64       `tfsdk:"max_concurrent_downloads_per_host"`
+	MaxRedirects                  types.Int64       `tfsdk:"max_redirects"`
+	AllowInsecureRedirects        types.Bool        `tfsdk:"allow_insecure_redirects"`
+	AllowCrossHostRedirects       types.Bool        `tfsdk:"allow_cross_host_redirects"`
+	ProxyURL                      types.String      `tfsdk:"proxy_url"`
+	ProxyUsername                 types.String      `tfsdk:"proxy_username"`
+	ProxyPassword                 types.String      `tfsdk:"proxy_password"`
+	NoProxy                       types.List        `tfsdk:"no_proxy"`
+	Netrc                         types.Bool        `tfsdk:"netrc"`
+	NetrcFile                     types.String      `tfsdk:"netrc_file"`
+	AllowedHosts                  types.List        `tfsdk:"allowed_hosts"`
+	DeniedHosts                   types.List        `tfsdk:"denied_hosts"`
+	BlockPrivateAddresses         types.Bool        `tfsdk:"block_private_addresses"`
+	Resolve                       types.Map         `tfsdk:"resolve"`
+	DNSServer                     types.String      `tfsdk:"dns_server"`
+	IPPreference                  types.String      `tfsdk:"ip_preference"`
+	Offline                       types.Bool        `tfsdk:"offline"`
+	CacheDir                      types.String      `tfsdk:"cache_dir"`
+	CacheLinkMode                 types.String      `tfsdk:"cache_link_mode"`
+	BaseDir                       types.String      `tfsdk:"base_dir"`
+	FollowSymlinks                types.Bool        `tfsdk:"follow_symlinks"`
+	TLS                           *TLSModel         `tfsdk:"tls"`
+	Retry                         *RetryModel       `tfsdk:"retry"`
+	Credentials                   []CredentialModel `tfsdk:"credentials"`
+	URLRewrites                   []URLRewriteModel `tfsdk:"url_rewrite"`
+}
+
+// providerData is handed to the data source through provider data. The file
+// function gets its own from environmentProviderData.
+type providerData struct {
+	config  clientConfig
+	client  *downloadClient
+	tls     *TLSModel
+	cache   *artifactCache
+	sandbox *outputSandbox
+
+	// derived holds the clients of data sources that override client
+	// settings, so reads sharing an override also share its connections.
+	derivedMu sync.Mutex
+	derived   map[derivedClientKey]*downloadClient
+}
+
+// derivedClientKey identifies the client settings a data source overrides.
+type derivedClientKey struct {
+	tls       string
+	redirects redirectPolicy
+}
+
+// derivedClient returns the client kept for key, building it on first use.
+func (pd *providerData) derivedClient(key derivedClientKey, build func() (*downloadClient, error)) (*downloadClient, error) {
+	pd.derivedMu.Lock()
+	defer pd.derivedMu.Unlock()
+
+	if client, ok := pd.derived[key]; ok {
+		return client, nil
+	}
+
+	client, err := build()
+	if err != nil {
+		return nil, err
+	}
+
+	if pd.derived == nil {
+		pd.derived = map[derivedClientKey]*downloadClient{}
+	}
+	pd.derived[key] = client
+
+	return client, nil
 }
 
 func (d *DownloadProvider) Metadata(ctx context.Context, request provider.MetadataRequest, response *provider.MetadataResponse) {
@@ -23,11 +118,313 @@ func (d *DownloadProvider) Metadata(ctx context.Context, request provider.Metada
 
 func (d *DownloadProvider) Schema(ctx context.Context, request provider.SchemaRequest, response *provider.SchemaResponse) {
 	response.Schema = schema.Schema{
-		MarkdownDescription: "The download Terraform provider allows you to download a file from an http website.",
+		MarkdownDescription: "The download Terraform provider allows you to download a file from an http website. The provider block configures the `download_file` data source. It does not apply to the `file` function, since Terraform calls provider functions without configuring the provider.",
+		Attributes: map[string]schema.Attribute{
+			"timeout": schema.StringAttribute{
+				MarkdownDescription: "Overall time limit for a single request, including reading the body (e.g. `10m`). Defaults to no limit.",
+				Optional:            true,
+			},
+			"connect_timeout": schema.StringAttribute{
+				MarkdownDescription: "Time limit for establishing a TCP connection. Defaults to `30s`.",
+				Optional:            true,
+			},
+			"tls_handshake_timeout": schema.StringAttribute{
+				MarkdownDescription: "Time limit for the TLS handshake. Defaults to `10s`.",
+				Optional:            true,
+			},
+			"response_header_timeout": schema.StringAttribute{
+				MarkdownDescription: "Time limit for receiving the response headers once the request is sent. Defaults to `60s`.",
+				Optional:            true,
+			},
+			"idle_conn_timeout": schema.StringAttribute{
+				MarkdownDescription: "How long an idle keep-alive connection is kept open. Defaults to `90s`.",
+				Optional:            true,
+			},
+			"user_agent": schema.StringAttribute{
+				MarkdownDescription: "Product token prepended to the `User-Agent` header. The provider name and version are always included.",
+				Optional:            true,
+			},
+			"max_idle_conns": schema.Int64Attribute{
+				MarkdownDescription: "Maximum number of idle keep-alive connections across all hosts. Defaults to `100`.",
+				Optional:            true,
+			},
+			"max_idle_conns_per_host": schema.Int64Attribute{
+				MarkdownDescription: "Maximum number of idle keep-alive connections per host. Defaults to `2`.",
+				Optional:            true,
+			},
+			"max_conns_per_host": schema.Int64Attribute{
+				MarkdownDescription: "Maximum number of connections per host, including active ones. Defaults to no limit.",
+				Optional:            true,
+			},
+			"max_concurrent_downloads": schema.Int64Attribute{
+				MarkdownDescription: "Maximum number of simultaneous downloads across all hosts. Further downloads wait for a free slot. Defaults to no limit.",
+				Optional:            true,
+			},
+			"max_concurrent_downloads_per_host": schema.Int64Attribute{
+				MarkdownDescription: "Maximum number of simultaneous downloads from a single host. Defaults to no limit.",
+				Optional:            true,
+			},
+			"max_redirects": schema.Int64Attribute{
+				MarkdownDescription: "Maximum number of redirects followed for a download. Defaults to `10`.",
+				Optional:            true,
+			},
+			"allow_insecure_redirects": schema.BoolAttribute{
+				MarkdownDescription: "Follow redirects from `https` to `http`. Defaults to `false`.",
+				Optional:            true,
+			},
+			"allow_cross_host_redirects": schema.BoolAttribute{
+				MarkdownDescription: "Follow redirects to a different host. Authorization headers, cookies and `request_headers` are never sent to the other host. Defaults to `true`.",
+				Optional:            true,
+			},
+			"proxy_url": schema.StringAttribute{
+				MarkdownDescription: "URL of the proxy used for all requests (`http`, `https` or `socks5`). Defaults to the `HTTP_PROXY` and `HTTPS_PROXY` environment variables.",
+				Optional:            true,
+			},
+			"proxy_username": schema.StringAttribute{
+				MarkdownDescription: "Username used to authenticate with the proxy.",
+				Optional:            true,
+			},
+			"proxy_password": schema.StringAttribute{
+				MarkdownDescription: "Password used to authenticate with the proxy.",
+				Optional:            true,
+				Sensitive:           true,
+			},
+			"no_proxy": schema.ListAttribute{
+				MarkdownDescription: "Hosts, domains (`.example.com`) and CIDR ranges that bypass the proxy. Overrides the `NO_PROXY` environment variable.",
+				ElementType:         types.StringType,
+				Optional:            true,
+			},
+			"netrc": schema.BoolAttribute{
+				MarkdownDescription: "Read credentials from the netrc file (`$NETRC` or `~/.netrc`). Only `machine` entries are used, the `default` entry is ignored. Credentials blocks take precedence. Defaults to `false`.",
+				Optional:            true,
+			},
+			"netrc_file": schema.StringAttribute{
+				MarkdownDescription: "Path of the netrc file to read. Setting it enables `netrc`.",
+				Optional:            true,
+			},
+			"allowed_hosts": schema.ListAttribute{
+				MarkdownDescription: "Host name glob patterns (e.g. `*.example.com`) that may be downloaded from, including redirect targets. Does not apply to the `file` function. Defaults to all hosts.",
+				ElementType:         types.StringType,
+				Optional:            true,
+			},
+			"denied_hosts": schema.ListAttribute{
+				MarkdownDescription: "Host name glob patterns that may never be downloaded from. Takes precedence over `allowed_hosts`. Does not apply to the `file` function.",
+				ElementType:         types.StringType,
+				Optional:            true,
+			},
+			"block_private_addresses": schema.BoolAttribute{
+				MarkdownDescription: "Refuse connections to loopback, link-local (including cloud metadata endpoints), private, multicast and other reserved addresses, including NAT64 and 6to4 addresses that embed one of them. The check runs on the resolved address at connection time. Requests sent through a proxy are checked by resolving their host name before the request, and fail when it cannot be resolved. Can also be enabled with the `DOWNLOAD_BLOCK_PRIVATE_ADDRESSES` environment variable, which is the only way to enable it for the `file` function. Defaults to `false`.",
+				Optional:            true,
+			},
+			"resolve": schema.MapAttribute{
+				MarkdownDescription: "Static addresses for `host:port` pairs, like curl `--resolve`, e.g. `{ \"releases.example.com:443\" = \"10.0.0.5\" }`. Only the connection is redirected; TLS still uses the original host name for SNI and certificate verification.",
+				ElementType:         types.StringType,
+				Optional:            true,
+			},
+			"dns_server": schema.StringAttribute{
+				MarkdownDescription: "Address of the DNS server used to resolve download hosts instead of the system resolver, e.g. `10.0.0.2` or `10.0.0.2:5353`.",
+				Optional:            true,
+			},
+			"ip_preference": schema.StringAttribute{
+				MarkdownDescription: "Address family tried first when a host resolves to both IPv4 and IPv6 addresses: `ipv4` or `ipv6`. The other family is used as a fallback. Defaults to the system behavior.",
+				Optional:            true,
+			},
+			"offline": schema.BoolAttribute{
+				MarkdownDescription: "Never access the network and serve every download from `cache_dir`. Can also be enabled with the `DOWNLOAD_OFFLINE` environment variable, which is the only way to enable it for the `file` function. Defaults to `false`.",
+				Optional:            true,
+			},
+			"cache_dir": schema.StringAttribute{
+				MarkdownDescription: "Directory of the content addressed download cache of the data source. The `file` function only reads `DOWNLOAD_CACHE_DIR`. Bodies are stored by SHA256 with an index from URL to content. A `download_file` with a cached `verify_sha256` is served without network access, and offline mode reads from it. Can also be set with the `DOWNLOAD_CACHE_DIR` environment variable.",
+				Optional:            true,
+			},
+			"cache_link_mode": schema.StringAttribute{
+				MarkdownDescription: "How cached files are placed at their destination: `copy`, `hardlink` or `symlink`. Defaults to `copy`.",
+				Optional:            true,
+			},
+			"base_dir": schema.StringAttribute{
+				MarkdownDescription: "Directory that relative `output_file` paths are resolved against. Output paths that end up outside it, after cleaning and resolving symbolic links, are rejected. Can also be set with the `DOWNLOAD_BASE_DIR` environment variable, which also confines the `file` function.",
+				Optional:            true,
+			},
+			"follow_symlinks": schema.BoolAttribute{
+				MarkdownDescription: "Whether an output path may be an existing symbolic link. Downloads then replace the content of the file the link points to, except for links placed from the cache, which are replaced themselves. When `base_dir` is set the link must point inside it. Defaults to `true`.",
+				Optional:            true,
+			},
+		},
+		Blocks: map[string]schema.Block{
+			"tls":         providerTLSBlock(),
+			"retry":       providerRetryBlock(),
+			"credentials": providerCredentialsBlock(),
+			"url_rewrite": providerURLRewriteBlock(),
+		},
 	}
 }
 
 func (d *DownloadProvider) Configure(ctx context.Context, request provider.ConfigureRequest, response *provider.ConfigureResponse) {
+	var data DownloadProviderModel
+
+	response.Diagnostics.Append(request.Config.Get(ctx, &data)...)
+
+	if response.Diagnostics.HasError() {
+		return
+	}
+
+	cfg := defaultClientConfig(d.version)
+	cfg.UserAgent = data.UserAgent.ValueString()
+
+	setDuration(&cfg.Timeout, data.Timeout, path.Root("timeout"), &response.Diagnostics)
+	setDuration(&cfg.ConnectTimeout, data.ConnectTimeout, path.Root("connect_timeout"), &response.Diagnostics)
+	setDuration(&cfg.TLSHandshakeTimeout, data.TLSHandshakeTimeout, path.Root("tls_handshake_timeout"), &response.Diagnostics)
+	setDuration(&cfg.ResponseHeaderTimeout, data.ResponseHeaderTimeout, path.Root("response_header_timeout"), &response.Diagnostics)
+	setDuration(&cfg.IdleConnTimeout, data.IdleConnTimeout, path.Root("idle_conn_timeout"), &response.Diagnostics)
+
+	setInt(&cfg.MaxIdleConns, data.MaxIdleConns, path.Root("max_idle_conns"), &response.Diagnostics)
+	setInt(&cfg.MaxIdleConnsPerHost, data.MaxIdleConnsPerHost, path.Root("max_idle_conns_per_host"), &response.Diagnostics)
+	setInt(&cfg.MaxConnsPerHost, data.MaxConnsPerHost, path.Root("max_conns_per_host"), &response.Diagnostics)
+
+	var maxDownloads, maxDownloadsPerHost int
+	setInt(&maxDownloads, data.MaxConcurrentDownloads, path.Root("max_concurrent_downloads"), &response.Diagnostics)
+	setInt(&maxDownloadsPerHost, data.MaxConcurrentDownloadsPerHost, path.Root("max_concurrent_downloads_per_host"), &response.Diagnostics)
+	cfg.Limiter = newTransferLimiter(maxDownloads, maxDownloadsPerHost)
+
+	cfg.Redirects = cfg.Redirects.override(data.MaxRedirects, data.AllowInsecureRedirects, data.AllowCrossHostRedirects, path.Empty(), &response.Diagnostics)
+
+	if !data.ProxyURL.IsNull() && !data.ProxyURL.IsUnknown() {
+		proxyURL, err := parseProxyURL(data.ProxyURL.ValueString())
+		if err != nil {
+			response.Diagnostics.AddAttributeError(path.Root("proxy_url"), "Invalid proxy URL", err.Error())
+		}
+		cfg.ProxyURL = proxyURL
+	}
+
+	cfg.ProxyUsername = data.ProxyUsername.ValueString()
+	cfg.ProxyPassword = data.ProxyPassword.ValueString()
+	if cfg.ProxyPassword != "" && cfg.ProxyUsername == "" {
+		response.Diagnostics.AddAttributeError(path.Root("proxy_password"), "Missing proxy username",
+			"proxy_password requires proxy_username to be set.")
+	}
+
+	if !data.NoProxy.IsNull() && !data.NoProxy.IsUnknown() {
+		cfg.NoProxy = []string{}
+		response.Diagnostics.Append(data.NoProxy.ElementsAs(ctx, &cfg.NoProxy, false)...)
+	}
+
+	tlsConfig, err := buildTLSConfig(data.TLS)
+	if err != nil {
+		response.Diagnostics.AddAttributeError(path.Root("tls"), "Invalid TLS configuration", err.Error())
+	}
+	cfg.TLSConfig = tlsConfig
+
+	cfg.Retry = data.Retry.policy(ctx, &response.Diagnostics)
+	cfg.Credentials = credentialsFromModel(data.Credentials, &response.Diagnostics)
+	cfg.URLRewrites = urlRewritesFromModel(data.URLRewrites, &response.Diagnostics)
+
+	cfg.HostPolicy.BlockPrivateAddresses = data.BlockPrivateAddresses.ValueBool()
+	if data.BlockPrivateAddresses.IsNull() {
+		cfg.HostPolicy.BlockPrivateAddresses, err = boolFromEnv("DOWNLOAD_BLOCK_PRIVATE_ADDRESSES")
+		if err != nil {
+			response.Diagnostics.AddAttributeError(path.Root("block_private_addresses"), "Invalid address policy", err.Error())
+		}
+	}
+	if !data.AllowedHosts.IsNull() && !data.AllowedHosts.IsUnknown() {
+		response.Diagnostics.Append(data.AllowedHosts.ElementsAs(ctx, &cfg.HostPolicy.AllowedHosts, false)...)
+	}
+	if !data.DeniedHosts.IsNull() && !data.DeniedHosts.IsUnknown() {
+		response.Diagnostics.Append(data.DeniedHosts.ElementsAs(ctx, &cfg.HostPolicy.DeniedHosts, false)...)
+	}
+
+	if !data.Resolve.IsNull() && !data.Resolve.IsUnknown() {
+		overrides := map[string]string{}
+		response.Diagnostics.Append(data.Resolve.ElementsAs(ctx, &overrides, false)...)
+		cfg.Resolver.Overrides, err = parseResolveOverrides(overrides)
+		if err != nil {
+			response.Diagnostics.AddAttributeError(path.Root("resolve"), "Invalid resolve override", err.Error())
+		}
+	}
+
+	if data.DNSServer.ValueString() != "" {
+		cfg.Resolver.DNSServer, err = parseDNSServer(data.DNSServer.ValueString())
+		if err != nil {
+			response.Diagnostics.AddAttributeError(path.Root("dns_server"), "Invalid DNS server", err.Error())
+		}
+	}
+
+	cfg.Resolver.IPPreference, err = parseIPPreference(data.IPPreference.ValueString())
+	if err != nil {
+		response.Diagnostics.AddAttributeError(path.Root("ip_preference"), "Invalid IP preference", err.Error())
+	}
+
+	if data.Netrc.ValueBool() || data.NetrcFile.ValueString() != "" {
+		netrcPath := data.NetrcFile.ValueString()
+		explicit := netrcPath != ""
+		if !explicit {
+			netrcPath = defaultNetrcPath()
+		}
+
+		netrc, err := loadNetrc(netrcPath, explicit)
+		if err != nil {
+			response.Diagnostics.AddAttributeError(path.Root("netrc_file"), "Invalid netrc file", err.Error())
+		}
+		cfg.Netrc = netrc
+	}
+
+	cfg.Offline = data.Offline.ValueBool()
+	if data.Offline.IsNull() {
+		cfg.Offline, err = boolFromEnv("DOWNLOAD_OFFLINE")
+		if err != nil {
+			response.Diagnostics.AddAttributeError(path.Root("offline"), "Invalid offline mode", err.Error())
+		}
+	}
+
+	cacheDir := data.CacheDir.ValueString()
+	if data.CacheDir.IsNull() {
+		cacheDir = os.Getenv("DOWNLOAD_CACHE_DIR")
+	}
+
+	var cache *artifactCache
+	if cacheDir != "" {
+		cache, err = newArtifactCache(cacheDir, data.CacheLinkMode.ValueString())
+		if err != nil {
+			response.Diagnostics.AddAttributeError(path.Root("cache_dir"), "Invalid cache directory", err.Error())
+		}
+	} else if cfg.Offline {
+		response.Diagnostics.AddAttributeError(path.Root("cache_dir"), "Missing cache directory",
+			"Offline mode serves downloads from the local artifact cache, set cache_dir or DOWNLOAD_CACHE_DIR.")
+	}
+
+	baseDir := data.BaseDir.ValueString()
+	if data.BaseDir.IsNull() {
+		baseDir = os.Getenv("DOWNLOAD_BASE_DIR")
+	}
+
+	followSymlinks := data.FollowSymlinks.IsNull() || data.FollowSymlinks.ValueBool()
+
+	var linkRoots []string
+	if cache != nil && cache.linkMode == cacheLinkModeSymlink {
+		if !followSymlinks {
+			response.Diagnostics.AddAttributeError(path.Root("follow_symlinks"), "Conflicting configuration",
+				"The symlink cache_link_mode places downloads as symbolic links, which follow_symlinks = false refuses.")
+		}
+		linkRoots = append(linkRoots, cache.blobPath(""))
+	}
+
+	sandbox, err := newOutputSandbox(baseDir, followSymlinks, linkRoots...)
+	if err != nil {
+		response.Diagnostics.AddAttributeError(path.Root("base_dir"), "Invalid base directory", err.Error())
+	}
+
+	if response.Diagnostics.HasError() {
+		return
+	}
+
+	pd := &providerData{
+		config:  cfg,
+		client:  newDownloadClient(cfg),
+		tls:     data.TLS,
+		cache:   cache,
+		sandbox: sandbox,
+	}
+
+	response.DataSourceData = pd
 }
 
 func (d *DownloadProvider) DataSources(ctx context.Context) []func() datasource.DataSource {
@@ -38,7 +435,9 @@ func (d *DownloadProvider) DataSources(ctx context.Context) []func() datasource.
 
 func (d *DownloadProvider) Functions(ctx context.Context) []func() function.Function {
 	return []func() function.Function{
-		NewDownloadFileFunction,
+		func() function.Function {
+			return NewDownloadFileFunction(d.functionData)
+		},
 	}
 }
 
@@ -46,6 +445,113 @@ func (d *DownloadProvider) Resources(ctx context.Context) []func() resource.Reso
 	return []func() resource.Resource{}
 }
 
+// functionData returns the provider data of the file function. Terraform
+// calls provider functions on an instance that is never configured, so the
+// provider block never applies to them and the function is set up from the
+// environment alone.
+func (d *DownloadProvider) functionData() (*providerData, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.fnData == nil {
+		pd, err := environmentProviderData(d.version)
+		if err != nil {
+			return nil, err
+		}
+		d.fnData = pd
+	}
+
+	return d.fnData, nil
+}
+
+// environmentProviderData returns the provider data an unconfigured provider
+// reads from the environment. Like Configure, it refuses offline mode
+// without a cache rather than going online.
+func environmentProviderData(version string) (*providerData, error) {
+	cfg := defaultClientConfig(version)
+
+	var err error
+	cfg.Offline, err = boolFromEnv("DOWNLOAD_OFFLINE")
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.HostPolicy.BlockPrivateAddresses, err = boolFromEnv("DOWNLOAD_BLOCK_PRIVATE_ADDRESSES")
+	if err != nil {
+		return nil, err
+	}
+
+	var cache *artifactCache
+	if cacheDir := os.Getenv("DOWNLOAD_CACHE_DIR"); cacheDir != "" {
+		cache, err = newArtifactCache(cacheDir, "")
+		if err != nil {
+			return nil, fmt.Errorf("invalid DOWNLOAD_CACHE_DIR: %s", err)
+		}
+	} else if cfg.Offline {
+		return nil, errors.New("offline mode serves downloads from the local artifact cache, set DOWNLOAD_CACHE_DIR")
+	}
+
+	sandbox, err := newOutputSandbox(os.Getenv("DOWNLOAD_BASE_DIR"), true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DOWNLOAD_BASE_DIR: %s", err)
+	}
+
+	return &providerData{
+		config:  cfg,
+		client:  newDownloadClient(cfg),
+		cache:   cache,
+		sandbox: sandbox,
+	}, nil
+}
+
+// boolFromEnv returns the boolean environment variable name, which defaults
+// to false when it is not set.
+func boolFromEnv(name string) (bool, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q, expected true or false", name, value)
+	}
+
+	return b, nil
+}
+
+func setDuration(target *time.Duration, value types.String, p path.Path, diags *diag.Diagnostics) {
+	if value.IsNull() || value.IsUnknown() {
+		return
+	}
+
+	duration, err := time.ParseDuration(value.ValueString())
+	if err != nil {
+		diags.AddAttributeError(p, "Invalid duration", fmt.Sprintf("Could not parse %q: %s", value.ValueString(), err))
+		return
+	}
+
+	if duration < 0 {
+		diags.AddAttributeError(p, "Invalid duration", "Duration must not be negative.")
+		return
+	}
+
+	*target = duration
+}
+
+func setInt(target *int, value types.Int64, p path.Path, diags *diag.Diagnostics) {
+	if value.IsNull() || value.IsUnknown() {
+		return
+	}
+
+	if value.ValueInt64() < 0 {
+		diags.AddAttributeError(p, "Invalid value", "Value must not be negative.")
+		return
+	}
+
+	*target = int(value.ValueInt64())
+}
+
 func New(version string) func() provider.Provider {
 	return func() provider.Provider {
 		return &DownloadProvider{
@@ -0,0 +1,175 @@
+package provider
+
+import (
+	"fmt"
+	"golang.org/x/net/http/httpproxy"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// proxyFunc returns the proxy selection function for the transport. When no
+// proxy is configured the environment is used, with no_proxy rules from the
+// provider configuration taking precedence over NO_PROXY.
+func proxyFunc(cfg clientConfig) func(*http.Request) (*url.URL, error) {
+	proxyConfig := httpproxy.FromEnvironment()
+
+	if cfg.ProxyURL != nil {
+		proxyURL := *cfg.ProxyURL
+		if cfg.ProxyUsername != "" {
+			proxyURL.User = url.UserPassword(cfg.ProxyUsername, cfg.ProxyPassword)
+		}
+
+		proxyConfig.HTTPProxy = proxyURL.String()
+		proxyConfig.HTTPSProxy = proxyURL.String()
+	}
+
+	if cfg.NoProxy != nil {
+		proxyConfig.NoProxy = strings.Join(cfg.NoProxy, ",")
+	}
+
+	fn := proxyConfig.ProxyFunc()
+
+	return func(req *http.Request) (*url.URL, error) {
+		return fn(req.URL)
+	}
+}
+
+// proxyAddresses returns the host:port of every proxy the transport may
+// connect to.
+func proxyAddresses(cfg clientConfig) map[string]bool {
+	env := httpproxy.FromEnvironment()
+	candidates := []string{env.HTTPProxy, env.HTTPSProxy}
+	if cfg.ProxyURL != nil {
+		candidates = append(candidates, cfg.ProxyURL.String())
+	}
+
+	addresses := map[string]bool{}
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+
+		proxyURL, err := url.Parse(candidate)
+		if err != nil || proxyURL.Host == "" {
+			// The environment may omit the scheme, e.g. HTTP_PROXY=proxy:3128.
+			proxyURL, err = url.Parse("http://" + candidate)
+			if err != nil {
+				continue
+			}
+		}
+
+		port := proxyURL.Port()
+		if port == "" {
+			switch proxyURL.Scheme {
+			case "https":
+				port = "443"
+			case "socks5":
+				port = "1080"
+			default:
+				port = "80"
+			}
+		}
+
+		addresses[net.JoinHostPort(proxyURL.Hostname(), port)] = true
+	}
+
+	return addresses
+}
+
+// clientSecrets collects the configured secrets that must never show up in
+// an error message.
+func clientSecrets(cfg clientConfig) secretRedactor {
+	var secrets secretRedactor
+
+	passwords := []string{cfg.ProxyPassword}
+	if cfg.ProxyURL != nil && cfg.ProxyURL.User != nil {
+		if password, ok := cfg.ProxyURL.User.Password(); ok {
+			passwords = append(passwords, password)
+		}
+	}
+
+	for _, c := range append(cfg.Credentials, cfg.Netrc...) {
+		passwords = append(passwords, c.secrets()...)
+	}
+
+	for _, password := range passwords {
+		if password != "" {
+			secrets = append(secrets, password, url.QueryEscape(password), url.PathEscape(password))
+		}
+	}
+
+	return secrets
+}
+
+func parseProxyURL(value string) (*url.URL, error) {
+	proxyURL, err := url.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse proxy url: %s", redactURLError(err))
+	}
+
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q, expected http, https or socks5", proxyURL.Scheme)
+	}
+
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("proxy url %q has no host", proxyURL.Redacted())
+	}
+
+	return proxyURL, nil
+}
+
+// redactURLError strips the URL from url.Error values, since it may carry
+// credentials in its user info.
+func redactURLError(err error) error {
+	if urlErr, ok := err.(*url.Error); ok {
+		return urlErr.Err
+	}
+
+	return err
+}
+
+// secretRedactor replaces known secret values in error messages before they
+// are surfaced in diagnostics.
+type secretRedactor []string
+
+func (r secretRedactor) redact(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	msg := err.Error()
+	redacted := r.redactString(msg)
+
+	if redacted == msg {
+		return err
+	}
+
+	return &redactedError{err: err, msg: redacted}
+}
+
+func (r secretRedactor) redactString(s string) string {
+	for _, secret := range r {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, "REDACTED")
+		}
+	}
+
+	return s
+}
+
+type redactedError struct {
+	err error
+	msg string
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,97 @@
+package provider
+
+import (
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"net/http"
+	"strings"
+)
+
+const defaultMaxRedirects = 10
+
+// redirectPolicy decides which redirects a download follows.
+type redirectPolicy struct {
+	MaxRedirects   int
+	AllowInsecure  bool
+	AllowCrossHost bool
+}
+
+func defaultRedirectPolicy() redirectPolicy {
+	return redirectPolicy{
+		MaxRedirects:   defaultMaxRedirects,
+		AllowCrossHost: true,
+	}
+}
+
+// override applies the redirect attributes that are set, reporting invalid
+// values against the attribute paths under root.
+func (p redirectPolicy) override(maxRedirects types.Int64, allowInsecure types.Bool, allowCrossHost types.Bool, root path.Path, diags *diag.Diagnostics) redirectPolicy {
+	if !maxRedirects.IsNull() && !maxRedirects.IsUnknown() {
+		if maxRedirects.ValueInt64() < 0 {
+			diags.AddAttributeError(root.AtName("max_redirects"), "Invalid redirect limit", "max_redirects must not be negative.")
+		}
+		p.MaxRedirects = int(maxRedirects.ValueInt64())
+	}
+
+	if !allowInsecure.IsNull() && !allowInsecure.IsUnknown() {
+		p.AllowInsecure = allowInsecure.ValueBool()
+	}
+
+	if !allowCrossHost.IsNull() && !allowCrossHost.IsUnknown() {
+		p.AllowCrossHost = allowCrossHost.ValueBool()
+	}
+
+	return p
+}
+
+// checkRedirect is used as http.Client.CheckRedirect. Authorization,
+// cookies and the configured request headers set on the original request are
+// dropped as soon as the chain leaves the original host, even for
+// subdomains.
+func (p redirectPolicy) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > p.MaxRedirects {
+		return fmt.Errorf("stopped after %d redirects", p.MaxRedirects)
+	}
+
+	prev := via[len(via)-1]
+	if prev.URL.Scheme == "https" && req.URL.Scheme != "https" && !p.AllowInsecure {
+		return fmt.Errorf("refusing insecure redirect from %s to %s", prev.URL.Redacted(), req.URL.Redacted())
+	}
+
+	origin := strings.ToLower(via[0].URL.Host)
+	crossHost := false
+	for _, r := range append(via[1:], req) {
+		crossHost = crossHost || strings.ToLower(r.URL.Host) != origin
+	}
+
+	if crossHost {
+		if !p.AllowCrossHost {
+			return fmt.Errorf("refusing cross-host redirect from %s to %s", prev.URL.Redacted(), req.URL.Redacted())
+		}
+
+		headers := []string{"Authorization", "Cookie", "Cookie2"}
+		if names, ok := req.Context().Value(requestHeadersKey{}).([]string); ok {
+			headers = append(headers, names...)
+		}
+		for _, header := range headers {
+			req.Header.Del(header)
+		}
+	}
+
+	return nil
+}
+
+// redirectChain returns the URLs that answered resp's request chain with a
+// redirect, in the order they were requested. A direct download yields an
+// empty, not a nil, chain so that redirect_chain is known and empty.
+func redirectChain(resp *http.Response) []string {
+	chain := []string{}
+	for req := resp.Request; req.Response != nil; {
+		req = req.Response.Request
+		chain = append([]string{req.URL.String()}, chain...)
+	}
+
+	return chain
+}
@@ -0,0 +1,172 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRedirectPolicy_MaxRedirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		if n > 0 {
+			http.Redirect(w, r, fmt.Sprintf("/%d", n-1), http.StatusFound)
+			return
+		}
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	client := testClient(func(cfg *clientConfig) {
+		cfg.Redirects = redirectPolicy{MaxRedirects: 2, AllowCrossHost: true}
+	})
+	output := filepath.Join(t.TempDir(), "file.dat")
+
+	result, err := downloadFile(context.Background(), client, output, server.URL+"/2", requestOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.FinalURL != server.URL+"/0" {
+		t.Errorf("unexpected final URL %s", result.FinalURL)
+	}
+
+	expected := []string{server.URL + "/2", server.URL + "/1"}
+	if fmt.Sprint(result.RedirectChain) != fmt.Sprint(expected) {
+		t.Errorf("expected redirect chain %v, got %v", expected, result.RedirectChain)
+	}
+
+	result, err = downloadFile(context.Background(), client, output, server.URL+"/0", requestOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain, diags := types.ListValueFrom(context.Background(), types.StringType, result.RedirectChain)
+	if diags.HasError() || chain.IsNull() || len(chain.Elements()) != 0 {
+		t.Errorf("expected an empty redirect chain for a direct download, got %s %v", chain, diags)
+	}
+
+	_, err = downloadFile(context.Background(), client, output, server.URL+"/3", requestOptions{})
+	if err == nil || !strings.Contains(err.Error(), "stopped after 2 redirects") {
+		t.Errorf("expected redirect limit error, got %v", err)
+	}
+}
+
+func TestRedirectPolicy_Insecure(t *testing.T) {
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer plain.Close()
+
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, plain.URL, http.StatusFound)
+	}))
+	defer secure.Close()
+
+	for _, allow := range []bool{false, true} {
+		client := testClient(func(cfg *clientConfig) {
+			cfg.Redirects = redirectPolicy{MaxRedirects: 10, AllowInsecure: allow, AllowCrossHost: true}
+		})
+		client.httpClient.Transport.(*http.Transport).TLSClientConfig = secure.Client().Transport.(*http.Transport).TLSClientConfig
+
+		_, err := downloadFile(context.Background(), client, filepath.Join(t.TempDir(), "file.dat"), secure.URL, requestOptions{})
+		if allow && err != nil {
+			t.Errorf("expected insecure redirect to be followed, got %v", err)
+		}
+		if !allow && (err == nil || !strings.Contains(err.Error(), "refusing insecure redirect")) {
+			t.Errorf("expected insecure redirect to be refused, got %v", err)
+		}
+	}
+}
+
+func TestRedirectPolicy_CrossHost(t *testing.T) {
+	var authorization string
+	record := func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte("content"))
+	}
+
+	target := httptest.NewServer(http.HandlerFunc(record))
+	defer target.Close()
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/same":
+			http.Redirect(w, r, "/final", http.StatusFound)
+		case "/final":
+			record(w, r)
+		default:
+			http.Redirect(w, r, strings.Replace(target.URL, "127.0.0.1", "localhost", 1), http.StatusFound)
+		}
+	}))
+	defer origin.Close()
+
+	client := testClient(func(cfg *clientConfig) {
+		cfg.Redirects = redirectPolicy{MaxRedirects: 10}
+	})
+	_, err := downloadFile(context.Background(), client, filepath.Join(t.TempDir(), "file.dat"), origin.URL+"/remote", requestOptions{})
+	if err == nil || !strings.Contains(err.Error(), "refusing cross-host redirect") {
+		t.Errorf("expected cross-host redirect to be refused, got %v", err)
+	}
+
+	client = testClient(func(cfg *clientConfig) {
+		cfg.Redirects = defaultRedirectPolicy()
+	})
+	for path, expected := range map[string]string{"/same": "Bearer secret", "/remote": ""} {
+		req, err := client.newRequest(context.Background(), http.MethodGet, origin.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer secret")
+
+		resp, err := client.do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+
+		if authorization != expected {
+			t.Errorf("%s: expected authorization %q, got %q", path, expected, authorization)
+		}
+	}
+}
+
+func TestRedirectPolicy_CrossHostRequestHeaders(t *testing.T) {
+	var token string
+	record := func(w http.ResponseWriter, r *http.Request) {
+		token = r.Header.Get("Private-Token")
+		_, _ = w.Write([]byte("content"))
+	}
+
+	target := httptest.NewServer(http.HandlerFunc(record))
+	defer target.Close()
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/same":
+			http.Redirect(w, r, "/final", http.StatusFound)
+		case "/final":
+			record(w, r)
+		default:
+			http.Redirect(w, r, strings.Replace(target.URL, "127.0.0.1", "localhost", 1), http.StatusFound)
+		}
+	}))
+	defer origin.Close()
+
+	opts := requestOptions{Headers: map[string]string{"PRIVATE-TOKEN": "secret"}}
+	for path, expected := range map[string]string{"/same": "secret", "/remote": ""} {
+		if _, err := downloadFile(context.Background(), testClient(), filepath.Join(t.TempDir(), "file.dat"), origin.URL+path, opts); err != nil {
+			t.Fatal(err)
+		}
+
+		if token != expected {
+			t.Errorf("%s: expected token %q, got %q", path, expected, token)
+		}
+	}
+}
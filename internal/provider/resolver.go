@@ -0,0 +1,143 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"net"
+	"sort"
+	"strings"
+)
+
+const (
+	ipPreferenceIPv4 = "ipv4"
+	ipPreferenceIPv6 = "ipv6"
+)
+
+type dialFunc func(ctx context.Context, dialer *net.Dialer, network, address string) (net.Conn, error)
+
+// resolverConfig controls how the transport turns host names into
+// addresses. Only the dial is affected; TLS still uses the host name of the
+// request for SNI and certificate verification.
+type resolverConfig struct {
+	// Overrides maps a lower case host:port to the IP address to connect to,
+	// like curl --resolve.
+	Overrides    map[string]string
+	DNSServer    string
+	IPPreference string
+}
+
+func parseResolveOverrides(overrides map[string]string) (map[string]string, error) {
+	parsed := make(map[string]string, len(overrides))
+
+	for address, ip := range overrides {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil || host == "" || port == "" {
+			return nil, fmt.Errorf("invalid address %q, expected host:port", address)
+		}
+
+		if net.ParseIP(ip) == nil {
+			return nil, fmt.Errorf("invalid IP address %q for %s", ip, address)
+		}
+
+		parsed[strings.ToLower(net.JoinHostPort(strings.TrimSuffix(host, "."), port))] = ip
+	}
+
+	return parsed, nil
+}
+
+func parseDNSServer(server string) (string, error) {
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server, nil
+	}
+
+	if net.ParseIP(strings.Trim(server, "[]")) == nil {
+		return "", fmt.Errorf("invalid DNS server %q, expected an IP address with an optional port", server)
+	}
+
+	return net.JoinHostPort(strings.Trim(server, "[]"), "53"), nil
+}
+
+func parseIPPreference(preference string) (string, error) {
+	switch preference {
+	case "", ipPreferenceIPv4, ipPreferenceIPv6:
+		return preference, nil
+	default:
+		return "", fmt.Errorf("unknown IP preference %q, expected ipv4 or ipv6", preference)
+	}
+}
+
+// netResolver returns the resolver the dialer should use, or nil for the
+// system resolver.
+func (r resolverConfig) netResolver(dialer *net.Dialer) *net.Resolver {
+	if r.DNSServer == "" {
+		return nil
+	}
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			d := net.Dialer{Timeout: dialer.Timeout}
+			return d.DialContext(ctx, network, r.DNSServer)
+		},
+	}
+}
+
+// override returns the static address configured for host and port.
+func (r resolverConfig) override(host string, port string) (string, bool) {
+	ip, ok := r.Overrides[strings.ToLower(net.JoinHostPort(strings.TrimSuffix(host, "."), port))]
+	return ip, ok
+}
+
+// dial connects to address with dialer, applying the static overrides and
+// the address family preference.
+func (r resolverConfig) dial(ctx context.Context, dialer *net.Dialer, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return dialer.DialContext(ctx, network, address)
+	}
+
+	if ip, ok := r.override(host, port); ok {
+		tflog.Debug(ctx, "Using resolve override", map[string]interface{}{
+			"address": address,
+			"ip":      ip,
+		})
+		return dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+	}
+
+	if r.IPPreference == "" || net.ParseIP(host) != nil {
+		return dialer.DialContext(ctx, network, address)
+	}
+
+	resolver := dialer.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	addrs, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	preferIPv4 := r.IPPreference == ipPreferenceIPv4
+	sort.SliceStable(addrs, func(i, j int) bool {
+		return (addrs[i].IP.To4() != nil) == preferIPv4 && (addrs[j].IP.To4() != nil) != preferIPv4
+	})
+
+	var firstErr error
+	for _, addr := range addrs {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(addr.IP.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	if firstErr == nil {
+		firstErr = fmt.Errorf("no addresses found for %s", host)
+	}
+
+	return nil, firstErr
+}
@@ -0,0 +1,180 @@
+package provider
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"golang.org/x/net/dns/dnsmessage"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseResolveOverrides(t *testing.T) {
+	parsed, err := parseResolveOverrides(map[string]string{
+		"Releases.Example.com.:443": "10.0.0.5",
+		"[::1]:8080":                "::1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed["releases.example.com:443"] != "10.0.0.5" || parsed["[::1]:8080"] != "::1" {
+		t.Errorf("unexpected overrides %v", parsed)
+	}
+
+	for _, overrides := range []map[string]string{
+		{"releases.example.com": "10.0.0.5"},
+		{"releases.example.com:443": "releases.internal"},
+	} {
+		if _, err := parseResolveOverrides(overrides); err == nil {
+			t.Errorf("%v: expected error", overrides)
+		}
+	}
+}
+
+func TestParseDNSServer(t *testing.T) {
+	cases := map[string]string{
+		"10.0.0.2":      "10.0.0.2:53",
+		"10.0.0.2:5353": "10.0.0.2:5353",
+		"fd00::2":       "[fd00::2]:53",
+		"[fd00::2]:53":  "[fd00::2]:53",
+		"dns.internal":  "",
+	}
+
+	for server, expected := range cases {
+		actual, err := parseDNSServer(server)
+		if expected == "" {
+			if err == nil {
+				t.Errorf("%s: expected error", server)
+			}
+		} else if actual != expected {
+			t.Errorf("%s: expected %s, got %s (%v)", server, expected, actual, err)
+		}
+	}
+}
+
+func TestResolveOverride_KeepsTLSHostName(t *testing.T) {
+	var serverName string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serverName = r.TLS.ServerName
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	client := testClient(func(cfg *clientConfig) {
+		cfg.TLSConfig = &tls.Config{RootCAs: roots}
+		cfg.Resolver.Overrides = map[string]string{
+			"example.com:" + port:       "127.0.0.1",
+			"untrusted.example:" + port: "127.0.0.1",
+		}
+	})
+
+	dir := t.TempDir()
+	if _, err := downloadFile(context.Background(), client, filepath.Join(dir, "file.dat"), "https://example.com:"+port+"/file.dat", requestOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if serverName != "example.com" {
+		t.Errorf("expected SNI example.com, got %q", serverName)
+	}
+
+	_, err := downloadFile(context.Background(), client, filepath.Join(dir, "file.dat"), "https://untrusted.example:"+port+"/file.dat", requestOptions{})
+	if err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Errorf("expected certificate verification against the original host name, got %v", err)
+	}
+}
+
+func TestResolveOverride_BlockPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	client := testClient(func(cfg *clientConfig) {
+		cfg.HostPolicy.BlockPrivateAddresses = true
+		cfg.Resolver.Overrides = map[string]string{"artifacts.example.com:" + port: "127.0.0.1"}
+	})
+
+	_, err := downloadFile(context.Background(), client, filepath.Join(t.TempDir(), "file.dat"), "http://artifacts.example.com:"+port, requestOptions{})
+	if err == nil || !strings.Contains(err.Error(), "private or reserved address") {
+		t.Errorf("expected blocked connection, got %v", err)
+	}
+}
+
+// testDNSServer answers A queries for every name with 127.0.0.1.
+func testDNSServer(t *testing.T) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			var query dnsmessage.Message
+			if err := query.Unpack(buf[:n]); err != nil || len(query.Questions) == 0 {
+				continue
+			}
+
+			question := query.Questions[0]
+			reply := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: query.ID, Response: true, Authoritative: true},
+				Questions: query.Questions,
+			}
+			if question.Type == dnsmessage.TypeA {
+				reply.Answers = []dnsmessage.Resource{{
+					Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+					Body:   &dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}},
+				}}
+			}
+
+			packed, err := reply.Pack()
+			if err == nil {
+				_, _ = conn.WriteTo(packed, addr)
+			}
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestDNSServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	for _, preference := range []string{"", ipPreferenceIPv4, ipPreferenceIPv6} {
+		t.Run("preference "+preference, func(t *testing.T) {
+			client := testClient(func(cfg *clientConfig) {
+				cfg.Resolver.DNSServer = testDNSServer(t)
+				cfg.Resolver.IPPreference = preference
+			})
+
+			target := "http://artifacts.split-horizon.test:" + serverURL.Port() + "/file.dat"
+			if _, err := downloadFile(context.Background(), client, filepath.Join(t.TempDir(), "file.dat"), target, requestOptions{}); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
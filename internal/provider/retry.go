@@ -0,0 +1,314 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	retryErrorTimeout           = "timeout"
+	retryErrorConnectionRefused = "connection_refused"
+	retryErrorConnectionReset   = "connection_reset"
+	retryErrorUnexpectedEOF     = "unexpected_eof"
+	retryErrorDNS               = "dns"
+)
+
+var defaultRetryableStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+var defaultRetryableErrors = []string{
+	retryErrorTimeout,
+	retryErrorConnectionRefused,
+	retryErrorConnectionReset,
+	retryErrorUnexpectedEOF,
+}
+
+type RetryModel struct {
+	MaxAttempts          types.Int64  `tfsdk:"max_attempts"`
+	MinBackoff           types.String `tfsdk:"min_backoff"`
+	MaxBackoff           types.String `tfsdk:"max_backoff"`
+	RetryableStatusCodes types.List   `tfsdk:"retryable_status_codes"`
+	RetryableErrors      types.List   `tfsdk:"retryable_errors"`
+}
+
+func providerRetryBlock() schema.SingleNestedBlock {
+	return schema.SingleNestedBlock{
+		MarkdownDescription: "Retry behaviour for failed requests.",
+		Attributes: map[string]schema.Attribute{
+			"max_attempts": schema.Int64Attribute{
+				MarkdownDescription: "Maximum number of attempts per request, including the first one. Defaults to `1` (no retries).",
+				Optional:            true,
+			},
+			"min_backoff": schema.StringAttribute{
+				MarkdownDescription: "Delay before the first retry, doubled on every following attempt. Defaults to `1s`.",
+				Optional:            true,
+			},
+			"max_backoff": schema.StringAttribute{
+				MarkdownDescription: "Upper bound for the delay between attempts, including delays requested through `Retry-After`. Defaults to `30s`.",
+				Optional:            true,
+			},
+			"retryable_status_codes": schema.ListAttribute{
+				MarkdownDescription: "HTTP status codes that are retried. Defaults to `[429, 500, 502, 503, 504]`.",
+				ElementType:         types.Int64Type,
+				Optional:            true,
+			},
+			"retryable_errors": schema.ListAttribute{
+				MarkdownDescription: "Network errors that are retried: `timeout`, `connection_refused`, `connection_reset`, `unexpected_eof` and `dns`. Defaults to all but `dns`.",
+				ElementType:         types.StringType,
+				Optional:            true,
+			},
+		},
+	}
+}
+
+type retryPolicy struct {
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	StatusCodes map[int]bool
+	Errors      map[string]bool
+}
+
+func defaultRetryPolicy() retryPolicy {
+	policy := retryPolicy{
+		MaxAttempts: 1,
+		MinBackoff:  1 * time.Second,
+		MaxBackoff:  30 * time.Second,
+		StatusCodes: map[int]bool{},
+		Errors:      map[string]bool{},
+	}
+
+	for _, code := range defaultRetryableStatusCodes {
+		policy.StatusCodes[code] = true
+	}
+
+	for _, kind := range defaultRetryableErrors {
+		policy.Errors[kind] = true
+	}
+
+	return policy
+}
+
+func (m *RetryModel) policy(ctx context.Context, diags *diag.Diagnostics) retryPolicy {
+	policy := defaultRetryPolicy()
+	if m == nil {
+		return policy
+	}
+
+	setInt(&policy.MaxAttempts, m.MaxAttempts, path.Root("retry").AtName("max_attempts"), diags)
+	if policy.MaxAttempts < 1 {
+		diags.AddAttributeError(path.Root("retry").AtName("max_attempts"), "Invalid value", "max_attempts must be at least 1.")
+	}
+
+	setDuration(&policy.MinBackoff, m.MinBackoff, path.Root("retry").AtName("min_backoff"), diags)
+	setDuration(&policy.MaxBackoff, m.MaxBackoff, path.Root("retry").AtName("max_backoff"), diags)
+
+	if !m.RetryableStatusCodes.IsNull() && !m.RetryableStatusCodes.IsUnknown() {
+		var codes []int64
+		diags.Append(m.RetryableStatusCodes.ElementsAs(ctx, &codes, false)...)
+
+		policy.StatusCodes = map[int]bool{}
+		for _, code := range codes {
+			policy.StatusCodes[int(code)] = true
+		}
+	}
+
+	if !m.RetryableErrors.IsNull() && !m.RetryableErrors.IsUnknown() {
+		var kinds []string
+		diags.Append(m.RetryableErrors.ElementsAs(ctx, &kinds, false)...)
+
+		policy.Errors = map[string]bool{}
+		for _, kind := range kinds {
+			switch kind {
+			case retryErrorTimeout, retryErrorConnectionRefused, retryErrorConnectionReset, retryErrorUnexpectedEOF, retryErrorDNS:
+				policy.Errors[kind] = true
+			default:
+				diags.AddAttributeError(path.Root("retry").AtName("retryable_errors"), "Invalid value",
+					fmt.Sprintf("Unknown error kind %q.", kind))
+			}
+		}
+	}
+
+	return policy
+}
+
+// statusError is returned for responses with an unexpected status code.
+type statusError struct {
+	StatusCode       int
+	Status           string
+	RetryAfter       time.Duration
+	CredentialSource string
+	Body             string
+}
+
+func newStatusError(resp *http.Response) *statusError {
+	return &statusError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+func (e *statusError) Error() string {
+	msg := fmt.Sprintf("bad status: %s", e.Status)
+	if e.CredentialSource != "" {
+		msg += fmt.Sprintf(" (using credentials from %s)", e.CredentialSource)
+	}
+
+	if e.Body != "" {
+		msg += fmt.Sprintf(", response body: %q", e.Body)
+	}
+
+	return msg
+}
+
+// attemptsError reports every failed attempt of a retried request.
+type attemptsError struct {
+	errs []error
+}
+
+func (e *attemptsError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "giving up after %d attempts:", len(e.errs))
+	for i, err := range e.errs {
+		fmt.Fprintf(&b, "\n  attempt %d: %s", i+1, err)
+	}
+
+	return b.String()
+}
+
+func (e *attemptsError) Unwrap() []error {
+	return e.errs
+}
+
+// withRetry runs fn until it succeeds, fails with an error the policy does
+// not retry, or the attempts are exhausted.
+func (p retryPolicy) withRetry(ctx context.Context, url string, fn func() error) error {
+	var errs []error
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		errs = append(errs, err)
+
+		if attempt >= p.MaxAttempts || ctx.Err() != nil || !p.retryable(err) {
+			break
+		}
+
+		delay := p.backoff(attempt, err)
+		tflog.Warn(ctx, "Retrying request", map[string]interface{}{
+			"url":     url,
+			"attempt": attempt,
+			"delay":   delay.String(),
+			"error":   err.Error(),
+		})
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			errs = append(errs, ctx.Err())
+			return &attemptsError{errs: errs}
+		case <-timer.C:
+		}
+	}
+
+	if len(errs) == 1 {
+		return errs[0]
+	}
+
+	return &attemptsError{errs: errs}
+}
+
+func (p retryPolicy) retryable(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return p.StatusCodes[se.StatusCode]
+	}
+
+	kind := networkErrorKind(err)
+	return kind != "" && p.Errors[kind]
+}
+
+// backoff returns an exponential delay with jitter, or the server requested
+// delay for 429 and 503 responses carrying a Retry-After header.
+func (p retryPolicy) backoff(attempt int, err error) time.Duration {
+	var se *statusError
+	if errors.As(err, &se) && se.RetryAfter > 0 &&
+		(se.StatusCode == http.StatusTooManyRequests || se.StatusCode == http.StatusServiceUnavailable) {
+		return min(se.RetryAfter, p.MaxBackoff)
+	}
+
+	delay := p.MinBackoff << (attempt - 1)
+	if delay <= 0 || delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+
+	if delay <= 0 {
+		return 0
+	}
+
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
+
+func networkErrorKind(err error) string {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+
+	switch {
+	case errors.As(err, &dnsErr):
+		return retryErrorDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return retryErrorConnectionRefused
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE):
+		return retryErrorConnectionReset
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		return retryErrorUnexpectedEOF
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return retryErrorTimeout
+	}
+
+	return ""
+}
+
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+
+	return 0
+}
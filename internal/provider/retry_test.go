@@ -0,0 +1,165 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testRetries configures attempts with short backoffs.
+func testRetries(attempts int) func(cfg *clientConfig) {
+	return func(cfg *clientConfig) {
+		cfg.Retry.MaxAttempts = attempts
+		cfg.Retry.MinBackoff = 10 * time.Millisecond
+		cfg.Retry.MaxBackoff = 50 * time.Millisecond
+	}
+}
+
+func TestRetry_RecoversFromServerErrors(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch requests.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusBadGateway)
+		case 2:
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			_, _ = w.Write([]byte("content"))
+		}
+	}))
+	defer server.Close()
+
+	start := time.Now()
+	_, err := downloadFile(context.Background(), testClient(testRetries(3)), filepath.Join(t.TempDir(), "file.dat"), server.URL, requestOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if requests.Load() != 3 {
+		t.Errorf("expected 3 requests, got %d", requests.Load())
+	}
+
+	if time.Since(start) > 5*time.Second {
+		t.Error("Retry-After was not capped by max_backoff")
+	}
+}
+
+func TestRetry_ReportsEveryAttempt(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			hijacker := w.(http.Hijacker)
+			conn, _, _ := hijacker.Hijack()
+			_ = conn.Close()
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	_, err := downloadFile(context.Background(), testClient(testRetries(3)), filepath.Join(t.TempDir(), "file.dat"), server.URL, requestOptions{})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	for _, expected := range []string{
+		"giving up after 3 attempts",
+		"attempt 1: Get",
+		"attempt 2: bad status: 500 Internal Server Error",
+		"attempt 3: bad status: 500 Internal Server Error",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q in error:\n%s", expected, err)
+		}
+	}
+}
+
+func TestRetry_NotRetryableStatus(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	_, err := downloadFile(context.Background(), testClient(testRetries(3)), filepath.Join(t.TempDir(), "file.dat"), server.URL, requestOptions{})
+	if err == nil || err.Error() != "bad status: 404 Not Found" {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if requests.Load() != 1 {
+		t.Errorf("expected 1 request, got %d", requests.Load())
+	}
+}
+
+func TestRetry_HeadProbe(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("ETag", `"abc"`)
+	}))
+	defer server.Close()
+
+	remote, err := getRemoteFileMetadata(context.Background(), testClient(testRetries(2)), server.URL, requestOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if remote.ETag != `"abc"` {
+		t.Errorf("unexpected etag %q", remote.ETag)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d := parseRetryAfter("7"); d != 7*time.Second {
+		t.Errorf("expected 7s, got %s", d)
+	}
+
+	if d := parseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)); d < 58*time.Second || d > time.Minute {
+		t.Errorf("expected about 1m, got %s", d)
+	}
+
+	if d := parseRetryAfter("soon"); d != 0 {
+		t.Errorf("expected 0, got %s", d)
+	}
+}
+
+func TestRetry_NonIdempotentMethods(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	for _, tc := range []struct {
+		opts     requestOptions
+		requests int32
+	}{
+		{opts: requestOptions{Method: http.MethodPost}, requests: 1},
+		{opts: requestOptions{Method: http.MethodPost, RetryNonIdempotent: true}, requests: 2},
+		{opts: requestOptions{Method: http.MethodPut}, requests: 2},
+	} {
+		requests.Store(0)
+
+		_, err := downloadFile(context.Background(), testClient(testRetries(3)), filepath.Join(t.TempDir(), "file.dat"), server.URL, tc.opts)
+		if requests.Load() != tc.requests {
+			t.Errorf("%+v: expected %d requests, got %d", tc.opts, tc.requests, requests.Load())
+		}
+		if (err == nil) != (tc.requests == 2) {
+			t.Errorf("%+v: unexpected error %v", tc.opts, err)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package provider
+
+import (
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"regexp"
+	"strings"
+)
+
+type URLRewriteModel struct {
+	Prefix      types.String `tfsdk:"prefix"`
+	Regex       types.String `tfsdk:"regex"`
+	Replacement types.String `tfsdk:"replacement"`
+}
+
+func providerURLRewriteBlock() schema.ListNestedBlock {
+	return schema.ListNestedBlock{
+		MarkdownDescription: "Rules rewriting request URLs, e.g. to point public URLs at an internal mirror. Rules are evaluated in order and the first matching rule is applied.",
+		NestedObject: schema.NestedBlockObject{
+			Attributes: map[string]schema.Attribute{
+				"prefix": schema.StringAttribute{
+					MarkdownDescription: "URL prefix to replace with `replacement`. Conflicts with `regex`.",
+					Optional:            true,
+				},
+				"regex": schema.StringAttribute{
+					MarkdownDescription: "Regular expression matched against the URL. `replacement` may reference capture groups as `$1` or `${name}`. Conflicts with `prefix`.",
+					Optional:            true,
+				},
+				"replacement": schema.StringAttribute{
+					MarkdownDescription: "Replacement for the matched prefix or expression.",
+					Required:            true,
+				},
+			},
+		},
+	}
+}
+
+type urlRewrite struct {
+	prefix      string
+	regex       *regexp.Regexp
+	replacement string
+}
+
+func urlRewritesFromModel(models []URLRewriteModel, diags *diag.Diagnostics) []urlRewrite {
+	var rules []urlRewrite
+
+	for i, m := range models {
+		p := path.Root("url_rewrite").AtListIndex(i)
+		rule := urlRewrite{
+			prefix:      m.Prefix.ValueString(),
+			replacement: m.Replacement.ValueString(),
+		}
+
+		hasRegex := m.Regex.ValueString() != ""
+		switch {
+		case rule.prefix != "" && hasRegex:
+			diags.AddAttributeError(p, "Invalid URL rewrite", "Only one of prefix and regex can be set.")
+			continue
+		case rule.prefix == "" && !hasRegex:
+			diags.AddAttributeError(p, "Invalid URL rewrite", "One of prefix or regex must be set.")
+			continue
+		case hasRegex:
+			regex, err := regexp.Compile(m.Regex.ValueString())
+			if err != nil {
+				diags.AddAttributeError(p.AtName("regex"), "Invalid URL rewrite", fmt.Sprintf("Could not compile regex: %s", err))
+				continue
+			}
+			rule.regex = regex
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
+
+func (r urlRewrite) apply(u string) (string, bool) {
+	if r.regex != nil {
+		match := r.regex.FindStringSubmatchIndex(u)
+		if match == nil {
+			return u, false
+		}
+
+		var result []byte
+		result = r.regex.ExpandString(result, r.replacement, u, match)
+		return u[:match[0]] + string(result) + u[match[1]:], true
+	}
+
+	if strings.HasPrefix(u, r.prefix) {
+		return r.replacement + strings.TrimPrefix(u, r.prefix), true
+	}
+
+	return u, false
+}
+
+// rewriteURL applies the first matching rewrite rule to u.
+func rewriteURL(rules []urlRewrite, u string) string {
+	for _, rule := range rules {
+		if rewritten, ok := rule.apply(u); ok {
+			return rewritten
+		}
+	}
+
+	return u
+}
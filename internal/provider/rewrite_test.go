@@ -0,0 +1,60 @@
+package provider
+
+import (
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"testing"
+)
+
+func TestRewriteURL(t *testing.T) {
+	var diags diag.Diagnostics
+	rules := urlRewritesFromModel([]URLRewriteModel{
+		{
+			Prefix:      types.StringValue("https://releases.hashicorp.com/"),
+			Replacement: types.StringValue("https://mirror.internal/hashicorp/"),
+		},
+		{
+			Regex:       types.StringValue(`^https://github\.com/([^/]+)/([^/]+)/releases/download/(?P<tag>[^/]+)/`),
+			Replacement: types.StringValue("https://mirror.internal/github/$1/$2/${tag}/"),
+		},
+	}, &diags)
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+
+	cases := map[string]string{
+		"https://releases.hashicorp.com/terraform/1.11.1/terraform_1.11.1_linux_amd64.zip":  "https://mirror.internal/hashicorp/terraform/1.11.1/terraform_1.11.1_linux_amd64.zip",
+		"https://github.com/cli/cli/releases/download/v2.60.0/gh_2.60.0_linux_amd64.tar.gz": "https://mirror.internal/github/cli/cli/v2.60.0/gh_2.60.0_linux_amd64.tar.gz",
+		"https://example.com/file.dat": "https://example.com/file.dat",
+	}
+
+	for input, expected := range cases {
+		if got := rewriteURL(rules, input); got != expected {
+			t.Errorf("%s: expected %s, got %s", input, expected, got)
+		}
+	}
+}
+
+func TestRewriteURL_FirstMatchWins(t *testing.T) {
+	rules := []urlRewrite{
+		{prefix: "https://example.com/", replacement: "https://first/"},
+		{prefix: "https://example.com/", replacement: "https://second/"},
+	}
+
+	if got := rewriteURL(rules, "https://example.com/file"); got != "https://first/file" {
+		t.Errorf("unexpected rewrite %s", got)
+	}
+}
+
+func TestURLRewritesFromModel_Invalid(t *testing.T) {
+	var diags diag.Diagnostics
+	urlRewritesFromModel([]URLRewriteModel{
+		{Prefix: types.StringValue("a"), Regex: types.StringValue("b"), Replacement: types.StringValue("c")},
+		{Replacement: types.StringValue("c")},
+		{Regex: types.StringValue("("), Replacement: types.StringValue("c")},
+	}, &diags)
+
+	if diags.ErrorsCount() != 3 {
+		t.Errorf("expected 3 errors, got %v", diags)
+	}
+}
@@ -0,0 +1,173 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// outputSandbox confines output paths to a base directory. Paths are checked
+// after cleaning and after resolving symbolic links, so neither "../" nor a
+// link planted in the working directory can point a download elsewhere.
+type outputSandbox struct {
+	baseDir        string
+	followSymlinks bool
+
+	// linkRoots are directories a destination symlink may point into even
+	// though they are outside baseDir, such as the blobs of a cache that
+	// places files as symlinks.
+	linkRoots []string
+}
+
+func newOutputSandbox(baseDir string, followSymlinks bool, linkRoots ...string) (*outputSandbox, error) {
+	if baseDir == "" && followSymlinks {
+		return nil, nil
+	}
+
+	s := &outputSandbox{followSymlinks: followSymlinks}
+
+	if baseDir != "" {
+		resolved, err := resolveExisting(baseDir)
+		if err != nil {
+			return nil, fmt.Errorf("could not resolve base directory: %s", err)
+		}
+		fi, err := os.Stat(resolved)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			return nil, fmt.Errorf("base directory %s is not a directory", baseDir)
+		}
+		s.baseDir = resolved
+	}
+
+	for _, root := range linkRoots {
+		resolved, err := resolveExisting(root)
+		if err != nil {
+			return nil, err
+		}
+		s.linkRoots = append(s.linkRoots, resolved)
+	}
+
+	return s, nil
+}
+
+// resolve returns the path name refers to. Relative names are joined to the
+// base directory.
+func (s *outputSandbox) resolve(name string) (string, error) {
+	if s == nil {
+		return name, nil
+	}
+
+	if s.baseDir != "" && !filepath.IsAbs(name) {
+		name = filepath.Join(s.baseDir, name)
+	}
+
+	name, err := filepath.Abs(name)
+	if err != nil {
+		return "", err
+	}
+
+	dir, err := resolveExisting(filepath.Dir(name))
+	if err != nil {
+		return "", err
+	}
+	resolved := filepath.Join(dir, filepath.Base(name))
+
+	if s.baseDir != "" && !withinDir(s.baseDir, resolved) {
+		return "", fmt.Errorf("output path %s is outside the base directory %s", name, s.baseDir)
+	}
+
+	fi, err := os.Lstat(resolved)
+	if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		return resolved, nil
+	}
+
+	if !s.followSymlinks {
+		return "", fmt.Errorf("output path %s is a symbolic link and follow_symlinks is disabled", name)
+	}
+
+	target, err := resolveExisting(resolved)
+	if err != nil {
+		return "", err
+	}
+
+	if s.baseDir != "" && !withinDir(s.baseDir, target) {
+		allowed := false
+		for _, root := range s.linkRoots {
+			allowed = allowed || withinDir(root, target)
+		}
+		if !allowed {
+			return "", fmt.Errorf("output path %s links to %s, outside the base directory %s", name, target, s.baseDir)
+		}
+	}
+
+	return resolved, nil
+}
+
+// followOutputLink returns the file a symbolic link at name points to, so a
+// download replaces the content of that file rather than the link. Links to
+// blobs of cache are returned as is, since a blob must never be overwritten,
+// as are dangling links.
+func followOutputLink(name string, cache *artifactCache) string {
+	fi, err := os.Lstat(name)
+	if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		return name
+	}
+
+	target, err := filepath.EvalSymlinks(name)
+	if err != nil {
+		return name
+	}
+
+	if cache != nil {
+		blobs, err := filepath.EvalSymlinks(cache.blobPath(""))
+		if err == nil && withinDir(blobs, target) {
+			return name
+		}
+	}
+
+	return target
+}
+
+// resolveExisting resolves symbolic links in the longest existing prefix of
+// name and appends the components that do not exist yet.
+func resolveExisting(name string) (string, error) {
+	name, err := filepath.Abs(name)
+	if err != nil {
+		return "", err
+	}
+
+	var missing []string
+	for {
+		resolved, err := filepath.EvalSymlinks(name)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, missing...)...), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+
+		if _, lerr := os.Lstat(name); lerr == nil {
+			return "", fmt.Errorf("%s is a dangling symbolic link", name)
+		}
+
+		parent := filepath.Dir(name)
+		if parent == name {
+			return "", err
+		}
+		missing = append([]string{filepath.Base(name)}, missing...)
+		name = parent
+	}
+}
+
+func withinDir(dir string, name string) bool {
+	rel, err := filepath.Rel(dir, name)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,219 @@
+package provider
+
+import (
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testSandbox(t *testing.T, followSymlinks bool) (*outputSandbox, string, string) {
+	t.Helper()
+
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := filepath.Join(root, "base")
+	outside := filepath.Join(root, "outside")
+	for _, dir := range []string{base, outside} {
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sandbox, err := newOutputSandbox(base, followSymlinks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return sandbox, base, outside
+}
+
+func TestOutputSandbox_Resolve(t *testing.T) {
+	sandbox, base, outside := testSandbox(t, true)
+
+	if err := os.Symlink(outside, filepath.Join(base, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(base, "inner"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(base, "inner"), filepath.Join(base, "alias")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "target"), filepath.Join(base, "link.dat")); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]string{
+		"file.dat":                              filepath.Join(base, "file.dat"),
+		"sub/dir/file.dat":                      filepath.Join(base, "sub", "dir", "file.dat"),
+		"sub/../file.dat":                       filepath.Join(base, "file.dat"),
+		"alias/file.dat":                        filepath.Join(base, "inner", "file.dat"),
+		filepath.Join(base, "file.dat"):         filepath.Join(base, "file.dat"),
+		"../outside/file.dat":                   "",
+		"escape/file.dat":                       "",
+		"link.dat":                              "",
+		filepath.Join(outside, "file.dat"):      "",
+		filepath.Join(base, "..", "x", "y.dat"): "",
+	}
+
+	for name, expected := range cases {
+		resolved, err := sandbox.resolve(name)
+		if expected == "" {
+			if err == nil {
+				t.Errorf("%s: expected to be rejected, got %s", name, resolved)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", name, err)
+		} else if resolved != expected {
+			t.Errorf("%s: expected %s, got %s", name, expected, resolved)
+		}
+	}
+}
+
+func TestDownloadFileFunction_BaseDirFromEnvironment(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	_, base, outside := testSandbox(t, true)
+	t.Setenv("DOWNLOAD_BASE_DIR", base)
+
+	run := func(filename string) *function.FuncError {
+		return runFileFunction(testFileFunction(), types.StringValue(server.URL), types.StringValue(filename), types.TupleValueMust([]attr.Type{}, []attr.Value{}))
+	}
+
+	if err := run("file.dat"); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(base, "file.dat")); string(content) != "content" {
+		t.Errorf("expected the file in the base directory, got %q", content)
+	}
+
+	if err := run("../outside/file.dat"); err == nil {
+		t.Error("expected a path outside the base directory to be rejected")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "file.dat")); !os.IsNotExist(err) {
+		t.Errorf("expected nothing to be written outside, got %v", err)
+	}
+}
+
+func TestOutputSandbox_NoFollowSymlinks(t *testing.T) {
+	sandbox, base, _ := testSandbox(t, false)
+
+	if err := os.WriteFile(filepath.Join(base, "target.dat"), []byte("content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(base, "target.dat"), filepath.Join(base, "link.dat")); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := sandbox.resolve("link.dat")
+	if err == nil || !strings.Contains(err.Error(), "is a symbolic link") {
+		t.Errorf("expected symlink to be refused, got %v", err)
+	}
+
+	if _, err := sandbox.resolve("target.dat"); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestOutputSandbox_CacheLinkRoot(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := filepath.Join(root, "base")
+	if err := os.Mkdir(base, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err := newArtifactCache(filepath.Join(root, "cache"), cacheLinkModeSymlink)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := filepath.Join(root, "src.dat")
+	if err := os.WriteFile(src, []byte("content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.store("https://example.com/file.dat", src, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := restoreFromCache(cache, "https://example.com/file.dat", "", filepath.Join(base, "file.dat")); err != nil {
+		t.Fatal(err)
+	}
+
+	sandbox, err := newOutputSandbox(base, true, cache.blobPath(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolved, err := sandbox.resolve("file.dat")
+	if err != nil {
+		t.Fatalf("expected a link into the cache to be allowed, got %v", err)
+	}
+
+	if output := followOutputLink(resolved, cache); output != resolved {
+		t.Errorf("expected a link into the cache to be replaced itself, got %s", output)
+	}
+}
+
+func TestFollowOutputLink(t *testing.T) {
+	sandbox, base, _ := testSandbox(t, true)
+
+	target := filepath.Join(base, "target.dat")
+	if err := os.WriteFile(target, []byte("previous"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(base, "link.dat")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+
+	resolved, err := sandbox.resolve("link.dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if output := followOutputLink(resolved, nil); output != target {
+		t.Errorf("expected the link target, got %s", output)
+	}
+
+	dangling := filepath.Join(base, "dangling.dat")
+	if err := os.Symlink(filepath.Join(base, "missing.dat"), dangling); err != nil {
+		t.Fatal(err)
+	}
+	if output := followOutputLink(dangling, nil); output != dangling {
+		t.Errorf("expected a dangling link to be returned as is, got %s", output)
+	}
+
+	if output := followOutputLink(target, nil); output != target {
+		t.Errorf("expected a regular file to be returned as is, got %s", output)
+	}
+}
+
+func TestOutputSandbox_Disabled(t *testing.T) {
+	sandbox, err := newOutputSandbox("", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolved, err := sandbox.resolve("../file.dat")
+	if err != nil || resolved != "../file.dat" {
+		t.Errorf("expected the path to be unchanged, got %s, %v", resolved, err)
+	}
+}
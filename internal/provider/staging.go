@@ -0,0 +1,204 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var errResumeRejected = errors.New("the server rejected the range of the partial download")
+
+var errPartInUse = errors.New("the partial download is in use")
+
+// stagedFile is a file next to target. Downloads are written and verified
+// there and only renamed over target by commit, so a failed or interrupted
+// download leaves the previous file intact.
+type stagedFile struct {
+	target string
+	name   string
+
+	// lock is held while name is the resumable part of target, e.g.
+	// .file.dat.part. It is nil for a unique temporary file.
+	lock *os.File
+}
+
+// newStagedFile takes over the resumable part of target, so a part left by
+// an interrupted download is resumed. While another download of target holds
+// the part, a unique temporary file is reserved instead. The writers replace
+// that file rather than writing through it, so the result gets the usual
+// mode of a newly created file.
+func newStagedFile(target string) (*stagedFile, error) {
+	part := partPath(target)
+	if lock, err := lockPart(part); err == nil {
+		return &stagedFile{target: target, name: part, lock: lock}, nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	_ = tmp.Close()
+
+	return &stagedFile{target: target, name: tmp.Name()}, nil
+}
+
+func partPath(target string) string {
+	return filepath.Join(filepath.Dir(target), "."+filepath.Base(target)+".part")
+}
+
+// lockPart takes the exclusive lock guarding part, which the operating
+// system releases should the provider be killed.
+func lockPart(part string) (*os.File, error) {
+	name := part + ".lock"
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0o666)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tryLockFile(f); err != nil {
+		_ = f.Close()
+		return nil, errPartInUse
+	}
+
+	// The previous holder removes the lock file when it is done, so the lock
+	// is only valid if it was taken on the file still at name.
+	held, err := f.Stat()
+	if err == nil {
+		var current os.FileInfo
+		current, err = os.Stat(name)
+		if err == nil && !os.SameFile(held, current) {
+			err = errPartInUse
+		}
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// commit atomically renames the staged file over target.
+func (s *stagedFile) commit() error {
+	if err := os.Rename(s.name, s.target); err != nil {
+		return err
+	}
+	_ = os.Remove(resumePath(s.name))
+	s.name = ""
+	s.unlock()
+
+	return nil
+}
+
+// keep leaves a partial download in place after a failed transfer when it
+// can be resumed.
+func (s *stagedFile) keep() {
+	if s.lock == nil {
+		return
+	}
+
+	if _, err := os.Stat(resumePath(s.name)); err == nil {
+		s.name = ""
+		s.unlock()
+	}
+}
+
+// cleanup removes the staged file unless it was committed or kept.
+func (s *stagedFile) cleanup() {
+	if s.name != "" {
+		_ = os.Remove(s.name)
+		_ = os.Remove(resumePath(s.name))
+		s.name = ""
+	}
+	s.unlock()
+}
+
+func (s *stagedFile) unlock() {
+	if s.lock != nil {
+		_ = os.Remove(s.lock.Name())
+		_ = s.lock.Close()
+		s.lock = nil
+	}
+}
+
+// resumeRecord remembers the response a partial download was started from,
+// so the rest can be requested with an If-Range validator.
+type resumeRecord struct {
+	URL       string `json:"url"`
+	Validator string `json:"validator"`
+}
+
+func resumePath(filename string) string {
+	return filename + ".resume"
+}
+
+// loadResumeRecord returns the size of the partial download of url in
+// filename and the validator to resume it with.
+func loadResumeRecord(filename string, url string) (int64, string) {
+	content, err := os.ReadFile(resumePath(filename))
+	if err != nil {
+		return 0, ""
+	}
+
+	var r resumeRecord
+	if err := json.Unmarshal(content, &r); err != nil || r.URL != url || r.Validator == "" {
+		return 0, ""
+	}
+
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return 0, ""
+	}
+
+	return fi.Size(), r.Validator
+}
+
+// saveResumeRecord records the validator of header for the download of url
+// to filename. Responses without a strong validator cannot be resumed.
+func saveResumeRecord(filename string, url string, header http.Header) error {
+	validator := header.Get("ETag")
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		validator = header.Get("Last-Modified")
+	}
+
+	if validator == "" {
+		return removeResumeRecord(filename)
+	}
+
+	content, err := json.Marshal(resumeRecord{URL: url, Validator: validator})
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(resumePath(filename), content)
+}
+
+func removeResumeRecord(filename string) error {
+	if err := os.Remove(resumePath(filename)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// contentRangeStart returns the first byte position of a Content-Range
+// header such as "bytes 100-199/200".
+func contentRangeStart(header http.Header) (int64, error) {
+	value := header.Get("Content-Range")
+	spec, ok := strings.CutPrefix(value, "bytes ")
+	if ok {
+		spec, _, ok = strings.Cut(spec, "-")
+	}
+
+	start, err := strconv.ParseInt(spec, 10, 64)
+	if !ok || err != nil {
+		return 0, fmt.Errorf("invalid Content-Range %q", value)
+	}
+
+	return start, nil
+}
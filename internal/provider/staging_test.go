@@ -0,0 +1,284 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStagedFile(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "file.dat")
+	if err := os.WriteFile(target, []byte("previous"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	staged, err := newStagedFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(staged.name, []byte("discarded"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	staged.cleanup()
+
+	content, _ := os.ReadFile(target)
+	if string(content) != "previous" {
+		t.Errorf("expected the target to be intact, got %q", content)
+	}
+
+	staged, err = newStagedFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer staged.cleanup()
+	if err := os.WriteFile(staged.name, []byte("content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := staged.commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, _ = os.ReadFile(target)
+	if string(content) != "content" {
+		t.Errorf("expected the target to be replaced, got %q", content)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("expected only the target to remain, got %v", entries)
+	}
+}
+
+func TestDownloadWithCache_KeepsPreviousFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		_, _ = w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		_ = conn.Close()
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "file.dat")
+	if err := os.WriteFile(filename, []byte("previous"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := downloadWithCache(context.Background(), testClient(testRetries(1)), nil, filename, server.URL, nil, requestOptions{})
+	if err == nil {
+		t.Fatal("expected an interrupted download to fail")
+	}
+
+	content, _ := os.ReadFile(filename)
+	if string(content) != "previous" {
+		t.Errorf("expected the previous file to be intact, got %q", content)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("expected the temporary file to be removed, got %v", entries)
+	}
+}
+
+func TestStagedFile_PartInUse(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "file.dat")
+
+	first, err := newStagedFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := newStagedFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.lock == nil || second.lock != nil || first.name == second.name {
+		t.Fatalf("expected a unique file while the part is in use, got %s and %s", first.name, second.name)
+	}
+
+	if err := os.WriteFile(second.name, []byte("second"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(resumePath(second.name), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	second.keep()
+	second.cleanup()
+	if _, err := os.Stat(second.name); err == nil {
+		t.Error("expected the unique file not to be kept for resuming")
+	}
+
+	first.cleanup()
+
+	third, err := newStagedFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer third.cleanup()
+
+	if third.name != partPath(target) || third.lock == nil {
+		t.Errorf("expected the released part to be taken over, got %s", third.name)
+	}
+}
+
+// flakyServer serves body with ranges, cutting the connection of the first
+// request after cutAt bytes.
+type flakyServer struct {
+	mu          sync.Mutex
+	body        []byte
+	etag        string
+	cutAt       int
+	ignoreRange bool
+	ranges      []string
+}
+
+func (s *flakyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.ranges = append(s.ranges, r.Header.Get("Range")+" "+r.Header.Get("If-Range"))
+	body, etag, cutAt, ignoreRange := s.body, s.etag, s.cutAt, s.ignoreRange
+	s.cutAt = 0
+	s.mu.Unlock()
+
+	w.Header().Set("ETag", etag)
+
+	if cutAt > 0 {
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		_, _ = w.Write(body[:cutAt])
+		w.(http.Flusher).Flush()
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		_ = conn.Close()
+		return
+	}
+
+	if ignoreRange {
+		_, _ = w.Write(body)
+		return
+	}
+
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(body))
+}
+
+// update replaces the content served from the next request on.
+func (s *flakyServer) update(body []byte, etag string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.body, s.etag = body, etag
+}
+
+// requests returns the Range and If-Range headers of each request so far,
+// along with the current body.
+func (s *flakyServer) requests() (string, []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return strings.Join(s.ranges, ","), s.body
+}
+
+func TestDownloadWithCache_Resume(t *testing.T) {
+	for name, tc := range map[string]struct {
+		ignoreRange bool
+		changed     bool
+	}{
+		"resumed":        {},
+		"ranges ignored": {ignoreRange: true},
+		"changed":        {changed: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			server := &flakyServer{body: bytes.Repeat([]byte("0123456789"), 100), etag: `"v1"`, cutAt: 600, ignoreRange: tc.ignoreRange}
+			ts := httptest.NewServer(server)
+			defer ts.Close()
+
+			dir := t.TempDir()
+			filename := filepath.Join(dir, "file.dat")
+			client := testClient(testRetries(1))
+
+			if err := downloadWithCache(context.Background(), client, nil, filename, ts.URL, nil, requestOptions{}); err == nil {
+				t.Fatal("expected the cut connection to fail the download")
+			}
+
+			part := partPath(filename)
+			if fi, err := os.Stat(part); err != nil || fi.Size() != 600 {
+				t.Fatalf("expected a 600 byte part file, got %v, %v", fi, err)
+			}
+
+			if tc.changed {
+				server.update(bytes.Repeat([]byte("abcdefghij"), 100), `"v2"`)
+			}
+
+			if err := downloadWithCache(context.Background(), client, nil, filename, ts.URL, nil, requestOptions{}); err != nil {
+				t.Fatal(err)
+			}
+
+			ranges, body := server.requests()
+
+			content, _ := os.ReadFile(filename)
+			if !bytes.Equal(content, body) {
+				t.Errorf("unexpected content %q", content)
+			}
+
+			if ranges != ` ,bytes=600- "v1"` {
+				t.Errorf("unexpected range requests %q", ranges)
+			}
+
+			for _, leftover := range []string{part, resumePath(part), part + ".lock"} {
+				if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+					t.Errorf("expected %s to be removed, got %v", leftover, err)
+				}
+			}
+		})
+	}
+}
+
+func TestDownloadFile_ResumeRejected(t *testing.T) {
+	server := &flakyServer{body: []byte("content"), etag: `"v1"`}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	output := filepath.Join(t.TempDir(), "file.dat")
+	if err := os.WriteFile(output, []byte("content and more"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	header := http.Header{}
+	header.Set("ETag", `"v1"`)
+	if err := saveResumeRecord(output, ts.URL, header); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := downloadFile(context.Background(), testClient(testRetries(1)), output, ts.URL, requestOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	content, _ := os.ReadFile(output)
+	if string(content) != "content" {
+		t.Errorf("expected a full download after the range was rejected, got %q", content)
+	}
+
+	if ranges, _ := server.requests(); ranges != `bytes=16- "v1", ` {
+		t.Errorf("unexpected range requests %q", ranges)
+	}
+}
+
+func TestContentRangeStart(t *testing.T) {
+	header := http.Header{}
+	header.Set("Content-Range", "bytes 600-999/1000")
+	if start, err := contentRangeStart(header); err != nil || start != 600 {
+		t.Errorf("expected 600, got %d (%v)", start, err)
+	}
+
+	header.Set("Content-Range", "bytes */1000")
+	if _, err := contentRangeStart(header); err == nil {
+		t.Error("expected error")
+	}
+}
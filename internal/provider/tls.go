@@ -0,0 +1,449 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	dsschema "github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"os"
+	"software.sslmate.com/src/go-pkcs12"
+	"strings"
+)
+
+// TLSModel describes the `tls` block accepted by both the provider and the
+// download_file data source. Values set on the data source override the
+// provider values, see mergeTLSModel.
+type TLSModel struct {
+	CACertFile           types.String `tfsdk:"ca_cert_file"`
+	CACertPEM            types.String `tfsdk:"ca_cert_pem"`
+	ReplaceSystemCAPool  types.Bool   `tfsdk:"replace_system_ca_pool"`
+	ClientCertFile       types.String `tfsdk:"client_cert_file"`
+	ClientCertPEM        types.String `tfsdk:"client_cert_pem"`
+	ClientKeyFile        types.String `tfsdk:"client_key_file"`
+	ClientKeyPEM         types.String `tfsdk:"client_key_pem"`
+	ClientPKCS12File     types.String `tfsdk:"client_pkcs12_file"`
+	ClientPKCS12Base64   types.String `tfsdk:"client_pkcs12_base64"`
+	ClientPKCS12Password types.String `tfsdk:"client_pkcs12_password"`
+	MinVersion           types.String `tfsdk:"min_version"`
+	CipherSuites         types.List   `tfsdk:"cipher_suites"`
+	SPKISHA256Pins       types.List   `tfsdk:"spki_sha256_pins"`
+}
+
+var tlsAttributeDescriptions = map[string]string{
+	"ca_cert_file":           "Path to a PEM encoded CA bundle used to verify the server certificate.",
+	"ca_cert_pem":            "PEM encoded CA bundle used to verify the server certificate.",
+	"replace_system_ca_pool": "When `true`, only the configured CA bundle is trusted instead of adding it to the system pool. Defaults to `false`.",
+	"client_cert_file":       "Path to a PEM encoded client certificate for mutual TLS.",
+	"client_cert_pem":        "PEM encoded client certificate for mutual TLS.",
+	"client_key_file":        "Path to the PEM encoded private key of the client certificate.",
+	"client_key_pem":         "PEM encoded private key of the client certificate.",
+	"client_pkcs12_file":     "Path to a PKCS#12 bundle holding the client certificate and key. Both the legacy RC2/3DES and the AES/PBKDF2 encryption written by OpenSSL 3 are supported.",
+	"client_pkcs12_base64":   "Base64 encoded PKCS#12 bundle holding the client certificate and key.",
+	"client_pkcs12_password": "Password of the PKCS#12 bundle.",
+	"min_version":            "Minimum TLS version: `1.0`, `1.1`, `1.2` or `1.3`. Defaults to `1.2`.",
+	"cipher_suites":          "Cipher suites allowed for TLS 1.2 and earlier, by their IANA name (e.g. `TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384`). TLS 1.3 suites are not configurable. Defaults to the Go defaults.",
+	"spki_sha256_pins":       "Base64 encoded SHA256 hashes of the server's SubjectPublicKeyInfo, optionally prefixed with `sha256/`. The connection is refused unless a certificate presented by the server matches one of the pins. Verification against the trusted CAs still applies.",
+}
+
+var tlsListAttributes = map[string]bool{
+	"cipher_suites":    true,
+	"spki_sha256_pins": true,
+}
+
+var tlsSensitiveAttributes = map[string]bool{
+	"client_key_pem":         true,
+	"client_pkcs12_base64":   true,
+	"client_pkcs12_password": true,
+}
+
+func providerTLSBlock() schema.SingleNestedBlock {
+	attributes := map[string]schema.Attribute{}
+	for name, description := range tlsAttributeDescriptions {
+		if name == "replace_system_ca_pool" {
+			attributes[name] = schema.BoolAttribute{
+				MarkdownDescription: description,
+				Optional:            true,
+			}
+			continue
+		}
+
+		if tlsListAttributes[name] {
+			attributes[name] = schema.ListAttribute{
+				MarkdownDescription: description,
+				ElementType:         types.StringType,
+				Optional:            true,
+			}
+			continue
+		}
+
+		attributes[name] = schema.StringAttribute{
+			MarkdownDescription: description,
+			Optional:            true,
+			Sensitive:           tlsSensitiveAttributes[name],
+		}
+	}
+
+	return schema.SingleNestedBlock{
+		MarkdownDescription: "TLS settings for custom CA bundles, client certificates, protocol versions and public key pinning.",
+		Attributes:          attributes,
+	}
+}
+
+func dataSourceTLSBlock() dsschema.SingleNestedBlock {
+	attributes := map[string]dsschema.Attribute{}
+	for name, description := range tlsAttributeDescriptions {
+		if name == "replace_system_ca_pool" {
+			attributes[name] = dsschema.BoolAttribute{
+				MarkdownDescription: description,
+				Optional:            true,
+			}
+			continue
+		}
+
+		if tlsListAttributes[name] {
+			attributes[name] = dsschema.ListAttribute{
+				MarkdownDescription: description,
+				ElementType:         types.StringType,
+				Optional:            true,
+			}
+			continue
+		}
+
+		attributes[name] = dsschema.StringAttribute{
+			MarkdownDescription: description,
+			Optional:            true,
+			Sensitive:           tlsSensitiveAttributes[name],
+		}
+	}
+
+	return dsschema.SingleNestedBlock{
+		MarkdownDescription: "TLS settings overriding the provider `tls` block for this download. The CA certificate and the client certificate are each replaced as a whole when any of their attributes is set.",
+		Attributes:          attributes,
+	}
+}
+
+// mergeTLSModel returns base with the settings of override applied. The CA
+// source and the client identity are replaced as a whole when override sets
+// any of their attributes, so that a data source can switch from a file to
+// an inline value, or from a key pair to a PKCS#12 bundle, without colliding
+// with the provider settings.
+func mergeTLSModel(base *TLSModel, override *TLSModel) *TLSModel {
+	if override == nil {
+		return base
+	}
+
+	if base == nil {
+		return override
+	}
+
+	merged := *base
+
+	if isSet(override.CACertFile, override.CACertPEM) {
+		merged.CACertFile = override.CACertFile
+		merged.CACertPEM = override.CACertPEM
+	}
+
+	if isSet(override.ClientCertFile, override.ClientCertPEM, override.ClientKeyFile, override.ClientKeyPEM,
+		override.ClientPKCS12File, override.ClientPKCS12Base64, override.ClientPKCS12Password) {
+		merged.ClientCertFile = override.ClientCertFile
+		merged.ClientCertPEM = override.ClientCertPEM
+		merged.ClientKeyFile = override.ClientKeyFile
+		merged.ClientKeyPEM = override.ClientKeyPEM
+		merged.ClientPKCS12File = override.ClientPKCS12File
+		merged.ClientPKCS12Base64 = override.ClientPKCS12Base64
+		merged.ClientPKCS12Password = override.ClientPKCS12Password
+	}
+
+	mergeString(&merged.MinVersion, override.MinVersion)
+
+	if !override.CipherSuites.IsNull() {
+		merged.CipherSuites = override.CipherSuites
+	}
+
+	if !override.SPKISHA256Pins.IsNull() {
+		merged.SPKISHA256Pins = override.SPKISHA256Pins
+	}
+
+	if !override.ReplaceSystemCAPool.IsNull() {
+		merged.ReplaceSystemCAPool = override.ReplaceSystemCAPool
+	}
+
+	return &merged
+}
+
+// isSet reports whether any of values is known and not null.
+func isSet(values ...types.String) bool {
+	for _, value := range values {
+		if !value.IsNull() && !value.IsUnknown() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func mergeString(target *types.String, value types.String) {
+	if !value.IsNull() && !value.IsUnknown() {
+		*target = value
+	}
+}
+
+// buildTLSConfig loads the CA bundle and client certificate described by
+// model. A nil model yields a nil config, leaving the transport defaults.
+func buildTLSConfig(model *TLSModel) (*tls.Config, error) {
+	if model == nil {
+		return nil, nil
+	}
+
+	config := &tls.Config{}
+
+	caPEM, err := readPEM(model.CACertFile, model.CACertPEM, "ca_cert")
+	if err != nil {
+		return nil, err
+	}
+
+	if caPEM != nil {
+		var pool *x509.CertPool
+		if model.ReplaceSystemCAPool.ValueBool() {
+			pool = x509.NewCertPool()
+		} else {
+			pool, err = x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+		}
+
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("ca_cert: no PEM encoded certificates found")
+		}
+
+		config.RootCAs = pool
+	} else if model.ReplaceSystemCAPool.ValueBool() {
+		return nil, errors.New("replace_system_ca_pool requires ca_cert_file or ca_cert_pem")
+	}
+
+	cert, err := loadClientCertificate(model)
+	if err != nil {
+		return nil, err
+	}
+
+	if cert != nil {
+		config.Certificates = []tls.Certificate{*cert}
+	}
+
+	config.MinVersion, err = parseTLSVersion(model.MinVersion.ValueString())
+	if err != nil {
+		return nil, err
+	}
+
+	config.CipherSuites, err = parseCipherSuites(stringList(model.CipherSuites))
+	if err != nil {
+		return nil, err
+	}
+
+	pins, err := parseSPKIPins(stringList(model.SPKISHA256Pins))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pins) > 0 {
+		config.VerifyConnection = verifySPKIPins(pins)
+	}
+
+	return config, nil
+}
+
+// stringList returns the elements of a list of strings, ignoring null and
+// unknown elements.
+func stringList(list types.List) []string {
+	var values []string
+	for _, element := range list.Elements() {
+		if s, ok := element.(types.String); ok && !s.IsNull() && !s.IsUnknown() {
+			values = append(values, s.ValueString())
+		}
+	}
+
+	return values
+}
+
+func parseTLSVersion(version string) (uint16, error) {
+	switch version {
+	case "":
+		return 0, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+
+	return 0, fmt.Errorf("unknown min_version %q, expected 1.0, 1.1, 1.2 or 1.3", version)
+}
+
+func parseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	known := map[string]uint16{}
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+
+	var ids []uint16
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func parseSPKIPins(pins []string) (map[string]bool, error) {
+	parsed := map[string]bool{}
+	for _, pin := range pins {
+		pin = strings.TrimPrefix(pin, "sha256/")
+		sum, err := base64.StdEncoding.DecodeString(pin)
+		if err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("invalid spki_sha256_pins entry %q, expected a base64 encoded SHA256 hash", pin)
+		}
+		parsed[pin] = true
+	}
+
+	return parsed, nil
+}
+
+func spkiSHA256(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// pinMismatchError reports a server whose certificates match none of the
+// configured public key pins.
+type pinMismatchError struct {
+	Host      string
+	Presented string
+}
+
+func (e *pinMismatchError) Error() string {
+	return fmt.Sprintf("public key pin mismatch for %s: server presented sha256/%s", e.Host, e.Presented)
+}
+
+func verifySPKIPins(pins map[string]bool) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		if len(cs.PeerCertificates) == 0 {
+			return &pinMismatchError{Host: cs.ServerName}
+		}
+
+		for _, cert := range cs.PeerCertificates {
+			if pins[spkiSHA256(cert)] {
+				return nil
+			}
+		}
+
+		return &pinMismatchError{
+			Host:      cs.ServerName,
+			Presented: spkiSHA256(cs.PeerCertificates[0]),
+		}
+	}
+}
+
+func loadClientCertificate(model *TLSModel) (*tls.Certificate, error) {
+	certPEM, err := readPEM(model.ClientCertFile, model.ClientCertPEM, "client_cert")
+	if err != nil {
+		return nil, err
+	}
+
+	keyPEM, err := readPEM(model.ClientKeyFile, model.ClientKeyPEM, "client_key")
+	if err != nil {
+		return nil, err
+	}
+
+	p12, err := readPKCS12(model)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case p12 != nil && (certPEM != nil || keyPEM != nil):
+		return nil, errors.New("client_pkcs12 cannot be combined with client_cert or client_key")
+	case p12 != nil:
+		key, leaf, chain, err := pkcs12.DecodeChain(p12, model.ClientPKCS12Password.ValueString())
+		if err != nil {
+			return nil, fmt.Errorf("could not decode client_pkcs12: %s", err)
+		}
+
+		cert := &tls.Certificate{PrivateKey: key, Leaf: leaf, Certificate: [][]byte{leaf.Raw}}
+		for _, c := range chain {
+			cert.Certificate = append(cert.Certificate, c.Raw)
+		}
+
+		return cert, nil
+	case certPEM == nil && keyPEM == nil:
+		return nil, nil
+	case certPEM == nil || keyPEM == nil:
+		return nil, errors.New("client_cert and client_key must be set together")
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("could not load client certificate: %s", err)
+	}
+
+	return &cert, nil
+}
+
+func readPEM(file types.String, inline types.String, name string) ([]byte, error) {
+	hasFile := file.ValueString() != ""
+	hasInline := inline.ValueString() != ""
+
+	switch {
+	case hasFile && hasInline:
+		return nil, fmt.Errorf("only one of %s_file and %s_pem can be set", name, name)
+	case hasFile:
+		content, err := os.ReadFile(file.ValueString())
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s_file: %s", name, err)
+		}
+		return content, nil
+	case hasInline:
+		return []byte(inline.ValueString()), nil
+	}
+
+	return nil, nil
+}
+
+func readPKCS12(model *TLSModel) ([]byte, error) {
+	hasFile := model.ClientPKCS12File.ValueString() != ""
+	hasInline := model.ClientPKCS12Base64.ValueString() != ""
+
+	switch {
+	case hasFile && hasInline:
+		return nil, errors.New("only one of client_pkcs12_file and client_pkcs12_base64 can be set")
+	case hasFile:
+		content, err := os.ReadFile(model.ClientPKCS12File.ValueString())
+		if err != nil {
+			return nil, fmt.Errorf("could not read client_pkcs12_file: %s", err)
+		}
+		return content, nil
+	case hasInline:
+		content, err := base64.StdEncoding.DecodeString(model.ClientPKCS12Base64.ValueString())
+		if err != nil {
+			return nil, fmt.Errorf("could not decode client_pkcs12_base64: %s", err)
+		}
+		return content, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,308 @@
+package provider
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"software.sslmate.com/src/go-pkcs12"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildTLSConfig_ClientAuth(t *testing.T) {
+	caCert, caKey := testCertificate(t, "client-ca", nil, nil)
+	clientCert, clientKey := testCertificate(t, "client", caCert, caKey)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(caCert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	serverCAPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+
+	keyFile := filepath.Join(t.TempDir(), "client.key")
+	if err := os.WriteFile(keyFile, testKeyPEM(t, clientKey), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// OpenSSL 3 writes AES/PBKDF2 bundles by default, older releases RC2.
+	modernP12, err := pkcs12.Modern.Encode(clientKey, clientCert, []*x509.Certificate{caCert}, "changeit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacyP12, err := pkcs12.LegacyRC2.Encode(clientKey, clientCert, nil, "changeit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p12File := filepath.Join(t.TempDir(), "client.p12")
+	if err := os.WriteFile(p12File, legacyP12, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		model       *TLSModel
+		expectError string
+	}{
+		"no ca": {
+			model:       nil,
+			expectError: "certificate",
+		},
+		"no client certificate": {
+			model: &TLSModel{
+				CACertPEM: types.StringValue(serverCAPEM),
+			},
+			expectError: "certificate required",
+		},
+		"client certificate": {
+			model: &TLSModel{
+				CACertPEM:           types.StringValue(serverCAPEM),
+				ReplaceSystemCAPool: types.BoolValue(true),
+				ClientCertPEM:       types.StringValue(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: clientCert.Raw}))),
+				ClientKeyFile:       types.StringValue(keyFile),
+			},
+		},
+		"modern pkcs12": {
+			model: &TLSModel{
+				CACertPEM:            types.StringValue(serverCAPEM),
+				ClientPKCS12Base64:   types.StringValue(base64.StdEncoding.EncodeToString(modernP12)),
+				ClientPKCS12Password: types.StringValue("changeit"),
+			},
+		},
+		"legacy pkcs12": {
+			model: &TLSModel{
+				CACertPEM:            types.StringValue(serverCAPEM),
+				ClientPKCS12File:     types.StringValue(p12File),
+				ClientPKCS12Password: types.StringValue("changeit"),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			tlsConfig, err := buildTLSConfig(tc.model)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			client := testClient(func(cfg *clientConfig) {
+				cfg.TLSConfig = tlsConfig
+			})
+
+			output := filepath.Join(t.TempDir(), "file.dat")
+			_, err = downloadFile(context.Background(), client, output, server.URL, requestOptions{})
+			if tc.expectError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectError) {
+					t.Fatalf("expected error containing %q, got %v", tc.expectError, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			content, _ := os.ReadFile(output)
+			if string(content) != "client" {
+				t.Errorf("unexpected content %q", content)
+			}
+		})
+	}
+}
+
+func TestMergeTLSModel(t *testing.T) {
+	base := &TLSModel{
+		CACertFile:    types.StringValue("/etc/ca.pem"),
+		ClientKeyFile: types.StringValue("/etc/client.key"),
+	}
+	override := &TLSModel{
+		ClientKeyFile:       types.StringValue("/tmp/client.key"),
+		ReplaceSystemCAPool: types.BoolValue(true),
+	}
+
+	merged := mergeTLSModel(base, override)
+	if merged.CACertFile.ValueString() != "/etc/ca.pem" {
+		t.Errorf("expected provider ca_cert_file, got %s", merged.CACertFile)
+	}
+	if merged.ClientKeyFile.ValueString() != "/tmp/client.key" {
+		t.Errorf("expected data source client_key_file, got %s", merged.ClientKeyFile)
+	}
+	if !merged.ReplaceSystemCAPool.ValueBool() {
+		t.Error("expected replace_system_ca_pool to be overridden")
+	}
+
+	// Exclusive attributes of a group must not collide with the provider's.
+	base = &TLSModel{
+		CACertFile:     types.StringValue("/etc/ca.pem"),
+		ClientCertFile: types.StringValue("/etc/client.pem"),
+		ClientKeyFile:  types.StringValue("/etc/client.key"),
+	}
+	override = &TLSModel{
+		CACertPEM:          types.StringValue("-----BEGIN CERTIFICATE-----"),
+		ClientPKCS12Base64: types.StringValue("MIIK"),
+	}
+
+	merged = mergeTLSModel(base, override)
+	if !merged.CACertFile.IsNull() || merged.CACertPEM.ValueString() == "" {
+		t.Errorf("expected ca_cert_pem to replace ca_cert_file, got %s %s", merged.CACertFile, merged.CACertPEM)
+	}
+	if !merged.ClientCertFile.IsNull() || !merged.ClientKeyFile.IsNull() || merged.ClientPKCS12Base64.ValueString() == "" {
+		t.Errorf("expected client_pkcs12_base64 to replace the key pair, got %+v", merged)
+	}
+	if _, err := loadClientCertificate(merged); err == nil || strings.Contains(err.Error(), "cannot be combined") {
+		t.Errorf("expected only the PKCS#12 bundle to be loaded, got %v", err)
+	}
+}
+
+func TestBuildTLSConfig_Hardening(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("content"))
+	}))
+	server.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+
+	serverCAPEM := types.StringValue(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})))
+	serverPin := spkiSHA256(server.Certificate())
+	otherPin := base64.StdEncoding.EncodeToString(make([]byte, 32))
+
+	pins := func(values ...string) types.List {
+		list, _ := types.ListValueFrom(context.Background(), types.StringType, values)
+		return list
+	}
+
+	cases := map[string]struct {
+		model       *TLSModel
+		expectError string
+	}{
+		"matching pin": {
+			model: &TLSModel{CACertPEM: serverCAPEM, SPKISHA256Pins: pins(otherPin, "sha256/"+serverPin)},
+		},
+		"pin mismatch": {
+			model:       &TLSModel{CACertPEM: serverCAPEM, SPKISHA256Pins: pins(otherPin)},
+			expectError: "server presented sha256/" + serverPin,
+		},
+		"min version": {
+			model:       &TLSModel{CACertPEM: serverCAPEM, MinVersion: types.StringValue("1.3")},
+			expectError: "protocol version",
+		},
+		"cipher suites": {
+			model: &TLSModel{CACertPEM: serverCAPEM, CipherSuites: pins("TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256")},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			tlsConfig, err := buildTLSConfig(tc.model)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			client := testClient(func(cfg *clientConfig) {
+				cfg.TLSConfig = tlsConfig
+			})
+
+			_, err = downloadFile(context.Background(), client, filepath.Join(t.TempDir(), "file.dat"), server.URL, requestOptions{})
+			if tc.expectError == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tc.expectError) {
+				t.Fatalf("expected error containing %q, got %v", tc.expectError, err)
+			}
+
+			var pinErr *pinMismatchError
+			if errors.As(err, &pinErr) != (name == "pin mismatch") {
+				t.Errorf("unexpected pin mismatch error type %T", err)
+			}
+		})
+	}
+}
+
+func TestBuildTLSConfig_InvalidHardening(t *testing.T) {
+	cases := map[string]*TLSModel{
+		"min version": {MinVersion: types.StringValue("1.4")},
+		"cipher":      {CipherSuites: types.ListValueMust(types.StringType, []attr.Value{types.StringValue("TLS_RSA_WITH_RC4_128_SHA")})},
+		"pin":         {SPKISHA256Pins: types.ListValueMust(types.StringType, []attr.Value{types.StringValue("not-a-pin")})},
+	}
+
+	for name, model := range cases {
+		if _, err := buildTLSConfig(model); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func testCertificate(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent, parentKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert, key
+}
+
+func testKeyPEM(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	t.Helper()
+
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
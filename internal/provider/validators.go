@@ -0,0 +1,107 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// fileValidators are the ETag and Last-Modified validators of the response a
+// file was last downloaded from. They are kept in a sidecar next to the file
+// together with its checksum, so a file changed on disk is never revalidated.
+type fileValidators struct {
+	URL          string `json:"url"`
+	SHA256       string `json:"sha256"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+
+	// StatusCode and Header are those of the full response, which a 304
+	// Not Modified response to a revalidation stands in for.
+	StatusCode int         `json:"status_code,omitempty"`
+	Header     http.Header `json:"header,omitempty"`
+}
+
+func validatorsPath(filename string) string {
+	return filepath.Join(filepath.Dir(filename), "."+filepath.Base(filename)+".validators.json")
+}
+
+// loadValidators returns the validators stored for filename, or nil when
+// there are none for url or the file no longer has the recorded content.
+func loadValidators(filename string, url string) *fileValidators {
+	content, err := os.ReadFile(validatorsPath(filename))
+	if err != nil {
+		return nil
+	}
+
+	var v fileValidators
+	if err := json.Unmarshal(content, &v); err != nil {
+		return nil
+	}
+
+	if v.URL != url || (v.ETag == "" && v.LastModified == "") || !fileMatchesSHA256(filename, v.SHA256) {
+		return nil
+	}
+
+	return &v
+}
+
+// saveValidators records the validators of the response result was
+// downloaded from for filename. Responses without validators remove a stale
+// sidecar instead.
+func saveValidators(filename string, url string, result *downloadResult) error {
+	v := fileValidators{
+		URL:          url,
+		ETag:         result.Header.Get("ETag"),
+		LastModified: result.Header.Get("Last-Modified"),
+		StatusCode:   result.StatusCode,
+		Header:       result.Header,
+	}
+
+	if v.ETag == "" && v.LastModified == "" {
+		if err := os.Remove(validatorsPath(filename)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	sum, err := fileSHA256(filename)
+	if err != nil {
+		return err
+	}
+	v.SHA256 = sum
+
+	content, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(validatorsPath(filename), content)
+}
+
+// apply makes req conditional on the stored validators.
+func (v *fileValidators) apply(req *http.Request) {
+	if v == nil {
+		return
+	}
+
+	if v.ETag != "" {
+		req.Header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		req.Header.Set("If-Modified-Since", v.LastModified)
+	}
+}
+
+// restore describes a 304 Not Modified result by the response the file was
+// downloaded from. Sidecars written before responses were recorded leave
+// result as it is.
+func (v *fileValidators) restore(result *downloadResult) {
+	if v.StatusCode == 0 {
+		return
+	}
+
+	result.StatusCode = v.StatusCode
+	result.Header = v.Header
+}
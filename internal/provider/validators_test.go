@@ -0,0 +1,142 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidators_SaveAndLoad(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "file.dat")
+	if err := os.WriteFile(filename, []byte("content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	header := http.Header{}
+	header.Set("ETag", `"v1"`)
+	header.Set("Last-Modified", "Wed, 21 Oct 2015 07:28:00 GMT")
+	header.Set("Content-Type", "application/zip")
+	if err := saveValidators(filename, "https://example.com/file.dat", &downloadResult{StatusCode: http.StatusOK, Header: header}); err != nil {
+		t.Fatal(err)
+	}
+
+	v := loadValidators(filename, "https://example.com/file.dat")
+	if v == nil || v.ETag != `"v1"` || v.LastModified != "Wed, 21 Oct 2015 07:28:00 GMT" {
+		t.Fatalf("unexpected validators %+v", v)
+	}
+	if v.StatusCode != http.StatusOK || v.Header.Get("Content-Type") != "application/zip" {
+		t.Errorf("unexpected response %d %v", v.StatusCode, v.Header)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "https://example.com/file.dat", nil)
+	v.apply(req)
+	if req.Header.Get("If-None-Match") != `"v1"` || req.Header.Get("If-Modified-Since") == "" {
+		t.Errorf("unexpected conditional headers %v", req.Header)
+	}
+
+	if loadValidators(filename, "https://example.com/other.dat") != nil {
+		t.Error("expected no validators for another URL")
+	}
+
+	if err := os.WriteFile(filename, []byte("changed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if loadValidators(filename, "https://example.com/file.dat") != nil {
+		t.Error("expected no validators for a file changed on disk")
+	}
+
+	if err := saveValidators(filename, "https://example.com/file.dat", &downloadResult{StatusCode: http.StatusOK, Header: http.Header{}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(validatorsPath(filename)); !os.IsNotExist(err) {
+		t.Errorf("expected the sidecar to be removed, got %v", err)
+	}
+}
+
+func TestDownloadFile_NotModified(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.Header().Set("Date", "Thu, 22 Oct 2015 07:28:00 GMT")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	client := testClient()
+
+	output := filepath.Join(t.TempDir(), "file.dat")
+	if err := os.WriteFile(output, []byte("previous"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := http.Header{}
+	previous.Set("Content-Type", "application/zip")
+	previous.Set("Last-Modified", "Wed, 21 Oct 2015 07:28:00 GMT")
+	validators := &fileValidators{ETag: `"v1"`, StatusCode: http.StatusOK, Header: previous}
+
+	result, err := downloadFile(context.Background(), client, output, server.URL, requestOptions{Validators: validators})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, _ := os.ReadFile(output)
+	if !result.NotModified || string(content) != "previous" {
+		t.Errorf("expected the file to be left alone, got %+v and %q", result, content)
+	}
+
+	if result.StatusCode != http.StatusOK || result.Header.Get("Content-Type") != "application/zip" || result.Header.Get("Date") != "" {
+		t.Errorf("expected the previous response to be reported, got %d %v", result.StatusCode, result.Header)
+	}
+}
+
+func TestDownloadFileFunction_Revalidate(t *testing.T) {
+	version := "v1"
+	var statuses []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := fmt.Sprintf(`"%s"`, version)
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			statuses = append(statuses, fmt.Sprintf("%s %d", r.Method, http.StatusNotModified))
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		statuses = append(statuses, fmt.Sprintf("%s %d", r.Method, http.StatusOK))
+		_, _ = w.Write([]byte("release " + version))
+	}))
+	defer server.Close()
+
+	fn := testFileFunction()
+	filename := filepath.Join(t.TempDir(), "file.dat")
+
+	run := func() {
+		t.Helper()
+
+		err := runFileFunction(fn, types.StringValue(server.URL), types.StringValue(filename), types.TupleValueMust([]attr.Type{}, []attr.Value{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run()
+	version = "v2"
+	run()
+	run()
+
+	content, _ := os.ReadFile(filename)
+	if string(content) != "release v2" {
+		t.Errorf("expected the same-size change to be downloaded, got %q", content)
+	}
+
+	if strings.Join(statuses, ",") != "GET 200,GET 200,GET 304" {
+		t.Errorf("unexpected requests %v", statuses)
+	}
+}